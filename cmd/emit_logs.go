@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/emitter"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	emitLogsCmd.Flags().String("sequence", "", "Log sequence as inline JSON")
+	emitLogsCmd.Flags().String("sequence-file", "", "Path to a JSON file containing the log sequence")
+}
+
+// emitLogsCmd writes a log sequence, it is the entrypoint of the log pods
+var emitLogsCmd = &cobra.Command{
+	Use:     "emit-logs",
+	Short:   "Write a sequence of log lines",
+	Long:    "Write the lines of a log sequence to stdout and stderr with their repetitions and intervals, this is what runs inside the log pods. The Loki tenant and headers are read from the " + emitter.LokiTenantIDEnv + " and " + emitter.LokiHeadersEnv + " (a JSON object) environment variables. It exits with 0 once the sequence is done and with 1 when it is stopped before",
+	Example: `emit-logs --sequence='{"steps":[{"message":"disk full","repetition":3,"interval":5}]}'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		inlineSequence, _ := cmd.Flags().GetString("sequence")
+		sequenceFile, _ := cmd.Flags().GetString("sequence-file")
+
+		var sequence *emitter.Sequence
+		var err error
+		if sequenceFile != "" {
+			sequence, err = emitter.ReadSequence(sequenceFile)
+		} else {
+			sequence, err = emitter.ParseSequence([]byte(inlineSequence))
+		}
+		if err == nil && sequence.Loki != nil {
+			// The credentials are given through the environment, not the sequence
+			err = sequence.Loki.SetCredentialsFromEnv(os.Getenv)
+		}
+		if err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		err = emitter.Run(ctx, *sequence, os.Stdout, os.Stderr)
+		stop()
+		if err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+	},
+}
@@ -40,5 +40,25 @@ var eventsClearCmd = &cobra.Command{
 				knImpl.WaitForPodDeletion(pod.Namespace, pod.Name)
 			}
 		}
+
+		// Delete the ConfigMaps holding the messages of the pods
+		configMapList, err := knImpl.FetchConfigMapByLabels(namespace, podLabels)
+		if err != nil {
+			println(err.Error())
+			return
+		}
+		for _, configMap := range configMapList.Items {
+			knImpl.DeleteConfigMap(configMap.Namespace, configMap.Name)
+		}
+
+		// Delete the Secrets holding the Loki credentials of the pods
+		secretList, err := knImpl.FetchSecretByLabels(namespace, podLabels)
+		if err != nil {
+			println(err.Error())
+			return
+		}
+		for _, secret := range secretList.Items {
+			knImpl.DeleteSecret(secret.Namespace, secret.Name)
+		}
 	},
 }
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/emitter"
+	"github.com/Patrick-Ivann/observability-pusher/internal/kubernetes"
+	"github.com/Patrick-Ivann/observability-pusher/internal/sources"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// logSequenceFile is the key of the log sequence in the ConfigMap of a log pod
+const logSequenceFile = "sequence.json"
+
+// addEmitFlags registers the flags of the commands pushing log lines
+func addEmitFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64("jitter", 0, "Randomly shift every interval by up to this fraction of it e.g '--jitter=0.1' for ±10%")
+	cmd.Flags().String("stream", emitter.StreamStdout, "Stream the lines are written to: stdout or stderr")
+	cmd.Flags().Bool("local", false, "Write the lines from this process instead of a pod, to stdout and stderr, the syslog server or Loki. The default with --loki-push-url, --local=false pushing from a pod")
+	cmd.Flags().String("syslog-address", "", "Syslog server the lines are sent to instead of stdout and stderr e.g 'rsyslog:514'")
+	cmd.Flags().String("syslog-network", emitter.SyslogUDP, "Network of the syslog server: udp, tcp or tls")
+	cmd.Flags().String("syslog-protocol", emitter.SyslogRFC5424, "Syslog message format: rfc5424 or rfc3164")
+	cmd.Flags().String("syslog-framing", emitter.FramingOctetCounting, "Framing of syslog messages over tcp and tls: octet-counting or non-transparent")
+	cmd.Flags().String("syslog-facility", "user", "Syslog facility e.g user, daemon, auth or local0 to local7")
+	cmd.Flags().String("syslog-app-name", "", "Syslog APP-NAME, the name of the producing app when empty")
+	cmd.Flags().String("syslog-hostname", "", "Syslog HOSTNAME, the pod or machine name when empty")
+	cmd.Flags().String("syslog-structured-data", "", `Syslog STRUCTURED-DATA of rfc5424 messages e.g '[origin@32473 env="staging"]'`)
+	cmd.Flags().String("syslog-ca-file", "", "Path to the PEM certificate authority checking the syslog tls server")
+	cmd.Flags().String("syslog-server-name", "", "Name checked on the syslog tls server certificate")
+	cmd.Flags().Bool("syslog-insecure-skip-verify", false, "Do not check the syslog tls server certificate")
+	cmd.Flags().String("loki-push-url", "", "Loki address the lines are pushed to from this process instead of stdout and stderr e.g 'http://loki:3100', the stream labels being the pod labels")
+	cmd.Flags().String("loki-encoding", emitter.LokiProtobuf, "Encoding of the Loki push requests: protobuf (snappy compressed) or json")
+	cmd.Flags().String("tenant-id", "", "Tenant sent as the X-Scope-OrgID header of the Loki push requests")
+	cmd.Flags().StringArray("header", nil, "HTTP header added to the Loki push requests as Name=Value, repeatable e.g '--header=Authorization=Bearer <token>'")
+}
+
+// parseEmitFlags sets the jitter and the syslog or Loki output of the flags on
+// the sequence and validates it, the labels being the Loki stream labels
+func parseEmitFlags(cmd *cobra.Command, sequence *emitter.Sequence, labels Labels) error {
+	jitter, _ := cmd.Flags().GetFloat64("jitter")
+	syslogAddress, _ := cmd.Flags().GetString("syslog-address")
+	syslogNetwork, _ := cmd.Flags().GetString("syslog-network")
+	syslogProtocol, _ := cmd.Flags().GetString("syslog-protocol")
+	syslogFraming, _ := cmd.Flags().GetString("syslog-framing")
+	syslogFacility, _ := cmd.Flags().GetString("syslog-facility")
+	syslogAppName, _ := cmd.Flags().GetString("syslog-app-name")
+	syslogHostname, _ := cmd.Flags().GetString("syslog-hostname")
+	syslogStructuredData, _ := cmd.Flags().GetString("syslog-structured-data")
+	syslogCAFile, _ := cmd.Flags().GetString("syslog-ca-file")
+	syslogServerName, _ := cmd.Flags().GetString("syslog-server-name")
+	syslogInsecureSkipVerify, _ := cmd.Flags().GetBool("syslog-insecure-skip-verify")
+	lokiPushURL, _ := cmd.Flags().GetString("loki-push-url")
+	lokiEncoding, _ := cmd.Flags().GetString("loki-encoding")
+	tenantID, _ := cmd.Flags().GetString("tenant-id")
+	rawHeaders, _ := cmd.Flags().GetStringArray("header")
+
+	sequence.Jitter = jitter
+	if syslogAddress != "" {
+		// The CA is given by value so the pod does not need the file
+		var ca []byte
+		if syslogCAFile != "" {
+			var err error
+			ca, err = os.ReadFile(syslogCAFile)
+			if err != nil {
+				return fmt.Errorf("error reading syslog CA: %w", err)
+			}
+		}
+		sequence.Syslog = &emitter.Syslog{
+			Address:            syslogAddress,
+			Network:            syslogNetwork,
+			Protocol:           syslogProtocol,
+			Framing:            syslogFraming,
+			Facility:           syslogFacility,
+			AppName:            syslogAppName,
+			Hostname:           syslogHostname,
+			StructuredData:     syslogStructuredData,
+			CA:                 string(ca),
+			ServerName:         syslogServerName,
+			InsecureSkipVerify: syslogInsecureSkipVerify,
+		}
+	}
+	if lokiPushURL != "" {
+		headers, err := parseHeaders(rawHeaders)
+		if err != nil {
+			return err
+		}
+		sequence.Loki = &emitter.Loki{URL: lokiPushURL, Encoding: lokiEncoding, Labels: labels, TenantID: tenantID, Headers: headers}
+	}
+	return sequence.Validate()
+}
+
+// runsLocally tells whether the lines are written from this process, with
+// --local or by default when pushing to Loki as no pod is needed for it
+func runsLocally(cmd *cobra.Command) bool {
+	local, _ := cmd.Flags().GetBool("local")
+	lokiPushURL, _ := cmd.Flags().GetString("loki-push-url")
+	if lokiPushURL != "" && !cmd.Flags().Changed("local") {
+		return true
+	}
+	return local
+}
+
+// runSequencesLocally writes the sequences from this process, concurrently as
+// pods would, until they are done or interrupted
+func runSequencesLocally(sequences []emitter.Sequence) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, len(sequences))
+	for _, sequence := range sequences {
+		go func() {
+			errs <- emitter.Run(ctx, sequence, os.Stdout, os.Stderr)
+		}()
+	}
+	var runErrors []error
+	for range sequences {
+		if err := <-errs; err != nil {
+			runErrors = append(runErrors, err)
+		}
+	}
+	return errors.Join(runErrors...)
+}
+
+// addFormatFlags registers the flags selecting how dictionary events are written
+func addFormatFlags(cmd *cobra.Command) {
+	cmd.Flags().String("format", emitter.FormatJSON, "Format of the events: "+strings.Join(emitter.Formats, ", "))
+	cmd.Flags().String("timestamp-field", "", "Name of the timestamp field, the default of the format when empty e.g ts for logfmt or @timestamp for ecs, the json format only has one when set")
+	cmd.Flags().String("timestamp-format", "", "Format of the timestamp: rfc3339, rfc3339nano, unix, unix-ms, unix-ns or a Go time layout, the default of the format when empty")
+	cmd.Flags().String("template", "", `Go template of the template format e.g '{{.Timestamp}} {{.Level}} {{.ID}} {{.Text}}'`)
+}
+
+// parseFormatFlags returns the event format described by the flags
+func parseFormatFlags(cmd *cobra.Command) (emitter.Format, error) {
+	kind, _ := cmd.Flags().GetString("format")
+	timestampField, _ := cmd.Flags().GetString("timestamp-field")
+	timestampFormat, _ := cmd.Flags().GetString("timestamp-format")
+	eventTemplate, _ := cmd.Flags().GetString("template")
+
+	format := emitter.Format{Kind: kind, TimestampField: timestampField, TimestampFormat: timestampFormat, Template: eventTemplate}
+	if err := format.Validate(); err != nil {
+		return emitter.Format{}, err
+	}
+	return format, nil
+}
+
+// repeatedStep returns the sequence writing the step every interval seconds,
+// or once when the interval is -1
+func repeatedStep(cmd *cobra.Command, step emitter.Step, intervalInSecond int) emitter.Sequence {
+	stream, _ := cmd.Flags().GetString("stream")
+
+	step.Stream = stream
+	step.Repetition = 1
+	if intervalInSecond == -1 {
+		return emitter.Sequence{Steps: []emitter.Step{step}}
+	}
+	step.Interval = float64(intervalInSecond)
+	return emitter.Sequence{Steps: []emitter.Step{step}, Loop: true}
+}
+
+// dictionaryEvent returns the emitter event of a dictionary notification
+func dictionaryEvent(notification sources.Log, source string) *emitter.Event {
+	return &emitter.Event{ID: notification.ID, Flag: notification.Flag, Severity: notification.Severity, Text: notification.Text, Source: source}
+}
+
+// createLogPod creates the ConfigMap holding the sequence then the pod running emit-logs on it.
+// The messages are given to the pod as data, so quotes, $, backticks and
+// newlines are written byte for byte. The Loki credentials, left out of the
+// encoded sequence, are given through a Secret of the same name
+func createLogPod(knImpl *kubernetes.Client, namespace, name string, sequence emitter.Sequence, labels Labels, isPsaEnabled bool) error {
+	encodedSequence, err := sequence.Encode()
+	if err != nil {
+		return err
+	}
+	if err := knImpl.CreateConfigMap(namespace, name, labels, map[string]string{logSequenceFile: encodedSequence}); err != nil {
+		return err
+	}
+
+	var env []corev1.EnvVar
+	if sequence.Loki != nil {
+		credentials, err := sequence.Loki.CredentialsEnv()
+		if err != nil {
+			return err
+		}
+		if len(credentials) > 0 {
+			if err := knImpl.CreateSecret(namespace, name, labels, credentials); err != nil {
+				return err
+			}
+			env = kubernetes.SecretEnv(name, slices.Sorted(maps.Keys(credentials)))
+		}
+	}
+	args := []string{"emit-logs", "--sequence-file", fmt.Sprintf("%s/%s", kubernetes.LogSequencePath, logSequenceFile)}
+	return knImpl.CreateLogPod(namespace, name, args, labels, name, env, isPsaEnabled)
+}
@@ -1,8 +1,7 @@
 package cmd
 
 import (
-	"fmt"
-
+	"github.com/Patrick-Ivann/observability-pusher/internal/emitter"
 	"github.com/Patrick-Ivann/observability-pusher/internal/kubernetes"
 
 	"github.com/spf13/cobra"
@@ -14,6 +13,7 @@ func init() {
 	eventsPushCmd.Flags().String("message", "", "Message to print at regular intervals. IF a value is provided to event-id, this flag will fill the message template e.g '--message=value1,value2'")
 	eventsPushCmd.Flags().Int("interval", 5, "interval between repetitions of messages, if set to -1 the message will be emitted once")
 	eventsPushCmd.Flags().Var(&podLabels, "pod-labels", `Specify labels as "key:value,anotherkey:anothervalue"`)
+	addEmitFlags(eventsPushCmd)
 	eventsPushCmd.Flags().Bool("psa-enabled", false, "if the cluster has some Pod Security Admission enabled")
 
 	eventsPushCmd.Flags().String("image-pull-secret", "", "Name of the image pull secret")
@@ -40,6 +40,18 @@ var eventsPushCmd = &cobra.Command{
 
 		podLabels.Append(Labels{"obs-pusher": "events"})
 
+		sequence := repeatedStep(cmd, emitter.Step{Message: message}, intervalInSecond)
+		if err := parseEmitFlags(cmd, &sequence, podLabels); err != nil {
+			println(err.Error())
+			return
+		}
+		if runsLocally(cmd) {
+			if err := runSequencesLocally([]emitter.Sequence{sequence}); err != nil {
+				println(err.Error())
+			}
+			return
+		}
+
 		knImpl, err := kubernetes.NewClientset(registry, registryPullSecret, serviceAccount)
 		if err != nil {
 			println(err.Error())
@@ -72,12 +84,8 @@ var eventsPushCmd = &cobra.Command{
 			}
 		}
 
-		script := fmt.Sprintf(`while true; do echo '%s'; sleep %d; done`, message, intervalInSecond)
-		if intervalInSecond == -1 {
-			script = fmt.Sprintf(`echo '%s'`, message)
-		}
 		// Create a new pod
-		err = knImpl.CreateLogPod(namespace, applicationName, []string{script}, podLabels, isPsaEnabled)
+		err = createLogPod(knImpl, namespace, applicationName, sequence, podLabels, isPsaEnabled)
 
 		if err != nil {
 			println(err.Error())
@@ -1,11 +1,11 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 
+	"github.com/Patrick-Ivann/observability-pusher/internal/emitter"
 	"github.com/Patrick-Ivann/observability-pusher/internal/kubernetes"
 	"github.com/Patrick-Ivann/observability-pusher/internal/sources"
 	"github.com/spf13/cobra"
@@ -23,6 +23,8 @@ func init() {
 	eventsPushFromDictionaryCmd.Flags().Var(&podLabels, "pod-labels", `Specify labels as "key:value,anotherkey:anothervalue"`)
 	eventsPushFromDictionaryCmd.Flags().StringVar(&eventFilePath, "event-file", os.Getenv("HOME")+"/.obs-pusher/"+"dictionary.xml", "Path to the XML file for the event")
 	eventsPushFromDictionaryCmd.Flags().StringVar(&eventID, "event-id", "", "The ID of the event to generate")
+	addEmitFlags(eventsPushFromDictionaryCmd)
+	addFormatFlags(eventsPushFromDictionaryCmd)
 	eventsPushFromDictionaryCmd.Flags().Bool("psa-enabled", false, "if the cluster has some Pod Security Admission enabled")
 
 	eventsPushFromDictionaryCmd.Flags().String("image-pull-secret", "", "Name of the image pull secret")
@@ -48,7 +50,14 @@ var eventsPushFromDictionaryCmd = &cobra.Command{
 
 		podLabels.Append(Labels{"obs-pusher": "events"})
 
+		format, err := parseFormatFlags(cmd)
+		if err != nil {
+			println(err.Error())
+			return
+		}
+
 		// Use event file and ID if provided
+		step := emitter.Step{Message: message}
 		if eventID != "" {
 			dictionary, err := sources.ReadDictionary(eventFilePath)
 			if err != nil {
@@ -69,22 +78,20 @@ var eventsPushFromDictionaryCmd = &cobra.Command{
 				return
 			}
 
-			notifValuesArray := strings.Split(message, ",")
-			formattedMessage := selectedNotification.Text
-			for i, value := range notifValuesArray {
-				placeholder := fmt.Sprintf("{%d}", i)
-				formattedMessage = strings.ReplaceAll(formattedMessage, placeholder, value)
-			}
-
-			selectedNotification.Text = formattedMessage
-			jsonData, err := json.Marshal(selectedNotification)
-			if err != nil {
-				fmt.Println("Error generating JSON:", err)
-				return
+			selectedNotification.Text = selectedNotification.RenderText(strings.Split(message, ","))
+			step.Event = dictionaryEvent(*selectedNotification, applicationName)
+		}
+		sequence := repeatedStep(cmd, step, intervalInSecond)
+		sequence.Format = format
+		if err := parseEmitFlags(cmd, &sequence, podLabels); err != nil {
+			println(err.Error())
+			return
+		}
+		if runsLocally(cmd) {
+			if err := runSequencesLocally([]emitter.Sequence{sequence}); err != nil {
+				println(err.Error())
 			}
-
-			message = string(jsonData)
-
+			return
 		}
 
 		knImpl, err := kubernetes.NewClientset(registry, registryPullSecret, serviceAccount)
@@ -118,12 +125,8 @@ var eventsPushFromDictionaryCmd = &cobra.Command{
 				knImpl.WaitForPodDeletion(namespace, pod.Name)
 			}
 		}
-		script := fmt.Sprintf(`while true; do echo '%s'; sleep %d; done`, message, intervalInSecond)
-		if intervalInSecond == -1 {
-			script = fmt.Sprintf(`echo '%s'`, message)
-		}
 		// Create a new pod
-		err = knImpl.CreateLogPod(namespace, applicationName, []string{script}, podLabels, isPsaEnabled)
+		err = createLogPod(knImpl, namespace, applicationName, sequence, podLabels, isPsaEnabled)
 
 		if err != nil {
 			println(err.Error())
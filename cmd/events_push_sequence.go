@@ -1,13 +1,13 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"slices"
 	"strings"
 
+	"github.com/Patrick-Ivann/observability-pusher/internal/emitter"
 	"github.com/Patrick-Ivann/observability-pusher/internal/kubernetes"
 	"github.com/Patrick-Ivann/observability-pusher/internal/sources"
 	"github.com/spf13/cobra"
@@ -19,6 +19,9 @@ func init() {
 	eventsPushSequenceCmd.Flags().StringVar(&eventSequenceFilePath, "event-sequence-file", os.Getenv("HOME")+"/.obs-pusher/"+"events-sequence.json", "Path to the json file containing the sequence of events")
 	eventsPushSequenceCmd.Flags().String("namespace", "default", "Namespace to create the app in")
 	eventsPushSequenceCmd.Flags().StringVar(&eventFilePath, "event-file", os.Getenv("HOME")+"/.obs-pusher/"+"events.xml", "Path to the XML file for the event")
+	eventsPushSequenceCmd.Flags().Var(&podLabels, "pod-labels", `Labels added to the labels of every sequence as "key:value,anotherkey:anothervalue"`)
+	addEmitFlags(eventsPushSequenceCmd)
+	addFormatFlags(eventsPushSequenceCmd)
 	eventsPushSequenceCmd.Flags().Bool("psa-enabled", false, "if the cluster has some Pod Security Admission enabled")
 
 	eventsPushSequenceCmd.Flags().String("registry-path", "", "Registry path for the image")
@@ -26,37 +29,28 @@ func init() {
 	eventsPushSequenceCmd.Flags().String("service-account", "default", "Name of the ServiceAccount to use")
 }
 
-func generateLogs(sequenceEvents []sources.SequenceNotification, events []sources.Log) string {
-	script := "#!/bin/sh\n\n"
+// generateLogs returns the steps writing the sequence events on their stream
+// or the default one, the source naming the application in the events
+func generateLogs(sequenceEvents []sources.SequenceNotification, events []sources.Log, defaultStream, source string) []emitter.Step {
+	var steps []emitter.Step
 	for _, seqEvent := range sequenceEvents {
 		isInSlice := slices.ContainsFunc(events, func(c sources.Log) bool { return c.ID == seqEvent.ID })
 		if !isInSlice {
 			fmt.Printf("event id %s not in dictionary \n", seqEvent.ID)
 			continue
 		}
-		for i := 0; i < seqEvent.Repetition; i++ {
-			for _, event := range events {
-				if event.ID == seqEvent.ID {
-					println(event.Text)
-					formattedMessage := event.Text
-					for j, value := range seqEvent.Values {
-						placeholder := fmt.Sprintf("{%d}", j)
-						formattedMessage = strings.ReplaceAll(formattedMessage, placeholder, value)
-					}
-					event.Text = formattedMessage
-
-					jsonData, err := json.Marshal(event)
-					if err != nil {
-						fmt.Println("Error generating JSON:", err)
-						continue
-					}
-					script += fmt.Sprintf("echo \"%s\"\n", string(jsonData))
-					script += fmt.Sprintf("sleep %d\n", seqEvent.Interval)
-				}
+		stream := seqEvent.Stream
+		if stream == "" {
+			stream = defaultStream
+		}
+		for _, event := range events {
+			if event.ID == seqEvent.ID {
+				event.Text = event.RenderText(seqEvent.Values)
+				steps = append(steps, emitter.Step{Event: dictionaryEvent(event, source), Stream: stream, Repetition: seqEvent.Repetition, Interval: float64(seqEvent.Interval)})
 			}
 		}
 	}
-	return script
+	return steps
 }
 
 func parseLabels(labelStrings []string) map[string]string {
@@ -81,6 +75,15 @@ var eventsPushSequenceCmd = &cobra.Command{
 		registry, _ := cmd.Flags().GetString("registry-path")
 		registryPullSecret, _ := cmd.Flags().GetString("image-pull-secret")
 		serviceAccount, _ := cmd.Flags().GetString("service-account")
+		stream, _ := cmd.Flags().GetString("stream")
+
+		podLabels.Append(Labels{"obs-pusher": "events"})
+
+		format, err := parseFormatFlags(cmd)
+		if err != nil {
+			println(err.Error())
+			return
+		}
 
 		// Parse events from JSON files
 		eventDictionary, err := sources.ReadDictionary(eventFilePath)
@@ -99,6 +102,39 @@ var eventsPushSequenceCmd = &cobra.Command{
 			groupedSequenceEvents[seqEvent.Name] = append(groupedSequenceEvents[seqEvent.Name], seqEvent)
 		}
 
+		// Generate the sequence and the labels of every group
+		names := make([]string, 0, len(groupedSequenceEvents))
+		sequences := make([]emitter.Sequence, 0, len(groupedSequenceEvents))
+		groupLabels := make([]Labels, 0, len(groupedSequenceEvents))
+		for name, seqEvents := range groupedSequenceEvents {
+			// Collect labels for the current group of sequence events
+			allLabels := make(Labels)
+			for _, seqEvent := range seqEvents {
+				if len(seqEvent.Labels) > 0 {
+					seqLabels := parseLabels(seqEvent.Labels)
+					allLabels.Append(seqLabels)
+				}
+			}
+			allLabels.Append(podLabels)
+
+			// Generate logs based on the sequence events and events
+			sequence := emitter.Sequence{Steps: generateLogs(seqEvents, eventDictionary.Logs, stream, name), Format: format}
+			if err := parseEmitFlags(cmd, &sequence, allLabels); err != nil {
+				println(err.Error())
+				return
+			}
+			names = append(names, name)
+			sequences = append(sequences, sequence)
+			groupLabels = append(groupLabels, allLabels)
+		}
+
+		if runsLocally(cmd) {
+			if err := runSequencesLocally(sequences); err != nil {
+				println(err.Error())
+			}
+			return
+		}
+
 		knImpl, err := kubernetes.NewClientset(registry, registryPullSecret, serviceAccount)
 		if err != nil {
 			println(err.Error())
@@ -116,18 +152,8 @@ var eventsPushSequenceCmd = &cobra.Command{
 			knImpl.CreateNamespace(namespace)
 		}
 
-		for name, seqEvents := range groupedSequenceEvents {
-			// Collect labels for the current group of sequence events
-			allLabels := make(Labels)
-			for _, seqEvent := range seqEvents {
-				if len(seqEvent.Labels) > 0 {
-					seqLabels := parseLabels(seqEvent.Labels)
-					allLabels.Append(seqLabels)
-				}
-			}
-
-			// Generate logs based on the sequence events and events
-			script := generateLogs(seqEvents, eventDictionary.Logs)
+		for i, name := range names {
+			sequence, allLabels := sequences[i], groupLabels[i]
 
 			// Check if pod exists by fetching it based on labels
 			podList, err := knImpl.FetchPodByLabels(namespace, allLabels)
@@ -147,7 +173,7 @@ var eventsPushSequenceCmd = &cobra.Command{
 
 			println(name)
 			// Create a new pod
-			err = knImpl.CreateLogPod(namespace, name, []string{script}, allLabels, isPsaEnabled)
+			err = createLogPod(knImpl, namespace, name, sequence, allLabels, isPsaEnabled)
 
 			if err != nil {
 				println(err.Error())
@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 	"strings"
+
+	"github.com/spf13/cobra"
 )
 
 // Labels is a custom flag type for parsing "key:value" pairs
@@ -44,3 +46,39 @@ func (l *Labels) Append(newLabels Labels) {
 		(*l)[key] = value
 	}
 }
+
+// addLabelSetFlags registers the flags describing several label sets for a metric
+func addLabelSetFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("label-set", nil, `Emit one series per label set, repeatable e.g '--label-set=method:GET,status_code:200 --label-set=method:POST,status_code:500'`)
+	cmd.Flags().StringArray("label-values", nil, `Emit one series per combination of label values, repeatable e.g '--label-values=method:GET|POST,status_code:200|500'`)
+}
+
+// parseLabelSetFlags returns the label sets and label values given with --label-set and --label-values
+func parseLabelSetFlags(cmd *cobra.Command) ([]map[string]string, map[string][]string, error) {
+	rawLabelSets, _ := cmd.Flags().GetStringArray("label-set")
+	rawLabelValues, _ := cmd.Flags().GetStringArray("label-values")
+
+	var labelSets []map[string]string
+	for _, rawLabelSet := range rawLabelSets {
+		var labelSet Labels
+		if err := labelSet.Set(rawLabelSet); err != nil {
+			return nil, nil, err
+		}
+		labelSets = append(labelSets, labelSet)
+	}
+
+	var labelValues map[string][]string
+	for _, rawLabelValue := range rawLabelValues {
+		var labels Labels
+		if err := labels.Set(rawLabelValue); err != nil {
+			return nil, nil, err
+		}
+		if labelValues == nil {
+			labelValues = make(map[string][]string)
+		}
+		for key, values := range labels {
+			labelValues[key] = append(labelValues[key], strings.Split(values, "|")...)
+		}
+	}
+	return labelSets, labelValues, nil
+}
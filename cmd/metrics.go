@@ -23,6 +23,8 @@ func init() {
 	metricsCmd.AddCommand(metricsPushCmd)
 	metricsCmd.AddCommand(metricsClearCmd)
 	metricsCmd.AddCommand(metricsPushDictionaryCmd)
+	metricsCmd.AddCommand(metricsPushScenarioCmd)
+	metricsCmd.AddCommand(metricsTestAlertCmd)
 	metricsCmd.Flags().Var(&podLabels, "pod-labels", `Specify labels as "key:value,anotherkey:anothervalue"`)
 
 }
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/alerts"
+	"github.com/Patrick-Ivann/observability-pusher/internal/sources"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// alertRuleName is the PrometheusRule holding the alerts of the scenario
+const alertRuleName = "obs-pusher-alerts"
+
+// alertRunLabel is added to the alerts of a test with a value unique to the
+// run, so an alert still firing from a previous run does not pass the test
+const alertRunLabel = "obs_pusher_run"
+
+func init() {
+	addScenarioFlags(metricsTestAlertCmd)
+	metricsTestAlertCmd.Flags().String("alert", "", "Name of the scenario alert expected to fire")
+	metricsTestAlertCmd.Flags().String("prometheus-url", "", "Prometheus address polled for the alert state e.g 'http://prometheus:9090'")
+	metricsTestAlertCmd.Flags().String("alertmanager-url", "", "Alertmanager address polled for the alert instead of Prometheus e.g 'http://alertmanager:9093'")
+	metricsTestAlertCmd.Flags().Float64("timeout", 600, "Seconds to wait for the alert to fire before failing")
+	metricsTestAlertCmd.Flags().Float64("poll-interval", 15, "Interval in seconds between two polls of the alert state")
+	metricsTestAlertCmd.Flags().String("rule-labels", "", `Extra labels of the PrometheusRule e.g to match the Prometheus ruleSelector, as "key:value,anotherkey:anothervalue"`)
+	metricsTestAlertCmd.Flags().Bool("keep-rule", false, "Keep the PrometheusRule once the test is over")
+	metricsTestAlertCmd.Flags().StringArray("header", nil, "HTTP header added to the polling requests as Name=Value, repeatable")
+}
+
+// alertRules converts the scenario alerts to the rules of the PrometheusRule,
+// labelling them with the run
+func alertRules(scenarioAlerts []sources.Alert, run string) []monitoringv1.Rule {
+	var rules []monitoringv1.Rule
+	for _, alert := range scenarioAlerts {
+		labels := Labels{}
+		labels.Append(alert.Labels)
+		labels[alertRunLabel] = run
+		rule := monitoringv1.Rule{
+			Alert:       alert.Alert,
+			Expr:        intstr.FromString(alert.Expr),
+			Labels:      labels,
+			Annotations: alert.Annotations,
+		}
+		if alert.For != "" {
+			duration := monitoringv1.Duration(alert.For)
+			rule.For = &duration
+		}
+		if alert.KeepFiringFor != "" {
+			duration := monitoringv1.NonEmptyDuration(alert.KeepFiringFor)
+			rule.KeepFiringFor = &duration
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// newAlertSource builds the source polled for the alert state from the flags
+func newAlertSource(cmd *cobra.Command) (alerts.Source, error) {
+	prometheusURL, _ := cmd.Flags().GetString("prometheus-url")
+	alertmanagerURL, _ := cmd.Flags().GetString("alertmanager-url")
+	rawHeaders, _ := cmd.Flags().GetStringArray("header")
+	headers, err := parseHeaders(rawHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case prometheusURL != "" && alertmanagerURL != "":
+		return nil, fmt.Errorf("--prometheus-url and --alertmanager-url cannot be used together")
+	case prometheusURL != "":
+		return &alerts.Prometheus{URL: prometheusURL, Headers: headers}, nil
+	case alertmanagerURL != "":
+		return &alerts.Alertmanager{URL: alertmanagerURL, Headers: headers}, nil
+	default:
+		return nil, fmt.Errorf("--prometheus-url or --alertmanager-url is required to poll the alert state")
+	}
+}
+
+// metricsTestAlertCmd checks an alert fires on the metrics of a scenario
+var metricsTestAlertCmd = &cobra.Command{
+	Use:     "test-alert",
+	Short:   "Check an alert of a scenario fires",
+	Long:    "Create the alerts of a scenario file as a PrometheusRule, push the scenario metrics and wait for the alert to fire, exiting with 1 when it does not before the timeout",
+	Example: "--scenario-file=./scenario.yaml --alert=QueueTooLong --prometheus-url=http://prometheus:9090 --timeout=300",
+	Run: func(cmd *cobra.Command, args []string) {
+		alertName, _ := cmd.Flags().GetString("alert")
+		namespace, _ := cmd.Flags().GetString("namespace")
+		timeout, _ := cmd.Flags().GetFloat64("timeout")
+		pollInterval, _ := cmd.Flags().GetFloat64("poll-interval")
+		rawRuleLabels, _ := cmd.Flags().GetString("rule-labels")
+		keepRule, _ := cmd.Flags().GetBool("keep-rule")
+
+		source, err := newAlertSource(cmd)
+		if err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+		if pollInterval <= 0 {
+			println("--poll-interval must be positive")
+			os.Exit(1)
+		}
+		ruleLabels := Labels{}
+		if rawRuleLabels != "" {
+			if err := ruleLabels.Set(rawRuleLabels); err != nil {
+				println(err.Error())
+				os.Exit(1)
+			}
+		}
+		ruleLabels.Append(Labels{"obs-pusher": "metrics", "element": alertRuleName})
+
+		scenario, err := sources.ParseScenario(metricScenarioFilePath)
+		if err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+		found := false
+		for _, alert := range scenario.Alerts {
+			found = found || alert.Alert == alertName
+		}
+		if !found {
+			fmt.Printf("alert %q is not defined in the scenario alerts\n", alertName)
+			os.Exit(1)
+		}
+
+		// Interrupting the test still deletes the PrometheusRule
+		signalCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		knImpl, err := pushMetricScenario(cmd, scenario)
+		if err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+		run := strconv.FormatInt(time.Now().UnixNano(), 36)
+		if err := knImpl.CreatePrometheusRule(namespace, alertRuleName, ruleLabels, alertRules(scenario.Alerts, run)); err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Printf("waiting up to %vs for alert %s to fire\n", timeout, alertName)
+		ctx, cancel := context.WithTimeout(signalCtx, seconds(timeout))
+		err = alerts.WaitForFiring(ctx, source, alertName, map[string]string{alertRunLabel: run}, seconds(pollInterval))
+		cancel()
+		if !keepRule {
+			knImpl.DeletePrometheusRule(namespace, alertRuleName)
+		}
+		if signalCtx.Err() != nil {
+			fmt.Printf("FAIL: interrupted before alert %s fired\n", alertName)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("FAIL: %s\n", err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("PASS: alert %s is firing\n", alertName)
+	},
+}
@@ -1,22 +1,72 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"os"
+
 	"github.com/Patrick-Ivann/observability-pusher/internal/kubernetes"
+	"github.com/Patrick-Ivann/observability-pusher/internal/sinks"
+	"github.com/Patrick-Ivann/observability-pusher/internal/sources"
 	"github.com/spf13/cobra"
 )
 
 func init() {
 	metricsClearCmd.Flags().String("namespace", "", "Namespace to fetch resources in, if no value will scan the whole cluster")
 	metricsClearCmd.Flags().Var(&podLabels, "pod-labels", `Specify labels as "key:value,anotherkey:anothervalue"`)
+	addPushgatewayFlags(metricsClearCmd)
+	metricsClearCmd.Flags().StringArray("header", nil, "HTTP header added to the Pushgateway request as Name=Value, repeatable")
+	metricsClearCmd.Flags().String("name", "", "Name of producing app given to metrics push, the default Pushgateway job")
+	metricsClearCmd.Flags().String("tag-label", "", "Tag label given to metrics push, the default Pushgateway grouping with --tag-value")
+	metricsClearCmd.Flags().String("tag-value", "", "Tag value given to metrics push or push-from, the default Pushgateway grouping")
+	metricsClearCmd.Flags().String("metric", "", "Dictionary metric given to metrics push-from, its group being deleted instead of the one of metrics push")
+	metricsClearCmd.Flags().StringVar(&metricFilePath, "path", os.Getenv("HOME")+"/.obs-pusher/"+"metrics.xml", "path of the source xml, used with --metric")
+}
+
+// clearedPushgateway returns the Pushgateway group metrics push sends to with
+// the same --name and --tag-* flags, or the one of metrics push-from when
+// --metric is set, --job and --grouping overriding them as they do for pushes
+func clearedPushgateway(cmd *cobra.Command) (*sinks.Pushgateway, error) {
+	metricName, _ := cmd.Flags().GetString("metric")
+	if metricName == "" {
+		return newPushgateway(cmd, pushSinkDefaults(cmd))
+	}
+
+	dictionary, err := sources.ReadDictionary(metricFilePath)
+	if err != nil {
+		return nil, err
+	}
+	metric, err := findDictionaryMetric(dictionary, metricName)
+	if err != nil {
+		return nil, err
+	}
+	metricTagValue, _ := cmd.Flags().GetString("tag-value")
+	return newPushgateway(cmd, dictionarySinkDefaults(metric, metricTagValue))
 }
 
 var metricsClearCmd = &cobra.Command{
 	Use:   "clear",
 	Short: "Clear metrics related objects",
-	Long:  "Clear metrics related objects",
+	Long:  "Clear metrics related objects, or the group pushed to a Pushgateway when --pushgateway-url is set",
 	Run: func(cmd *cobra.Command, args []string) {
 
 		namespace, _ := cmd.Flags().GetString("namespace")
+		pushgatewayURL, _ := cmd.Flags().GetString("pushgateway-url")
+
+		// Pushed groups live in the Pushgateway, not in the cluster
+		if pushgatewayURL != "" {
+			pushgateway, err := clearedPushgateway(cmd)
+			if err != nil {
+				println(err.Error())
+				return
+			}
+			if err := pushgateway.Delete(context.Background()); err != nil {
+				println(err.Error())
+				return
+			}
+			fmt.Printf("group of job %s deleted from %s\n", pushgateway.Job, pushgatewayURL)
+			return
+		}
 
 		podLabels.Append(Labels{"obs-pusher": "metrics"})
 		knImpl, err := kubernetes.NewClientset("", "", "")
@@ -53,6 +103,24 @@ var metricsClearCmd = &cobra.Command{
 			}
 		}
 
+		podmonitors, err := knImpl.FetchPodMonitorByLabels(namespace, podLabels)
+		if err != nil {
+			println(err.Error())
+			return
+		}
+		for _, podMonitor := range podmonitors.Items {
+			knImpl.DeletePodMonitor(podMonitor.Namespace, podMonitor.Name)
+		}
+
+		prometheusrules, err := knImpl.FetchPrometheusRuleByLabels(namespace, podLabels)
+		if err != nil {
+			println(err.Error())
+			return
+		}
+		for _, prometheusRule := range prometheusrules.Items {
+			knImpl.DeletePrometheusRule(prometheusRule.Namespace, prometheusRule.Name)
+		}
+
 		// Check if pod exists by fetching it based on labels
 		podList, err := knImpl.FetchPodByLabels(namespace, podLabels)
 		if err != nil {
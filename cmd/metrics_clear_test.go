@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/sources"
+	"github.com/spf13/cobra"
+)
+
+func TestClearDeletesThePushedGroup(t *testing.T) {
+	requests := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.Method + " " + r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dictionaryPath := filepath.Join(t.TempDir(), "metrics.xml")
+	err := os.WriteFile(dictionaryPath, []byte(`<dictionary><metric name="application.part.proctime" fullyQualifiedName="application_part_proctime" tags="region=value"/></dictionary>`), 0o600)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	previousPath := metricFilePath
+	metricFilePath = dictionaryPath
+	defer func() { metricFilePath = previousPath }()
+
+	// The commands keep their flags, which are reset to push the default group
+	setFlags := func(cmd *cobra.Command, flags map[string]string) {
+		t.Helper()
+		for _, name := range []string{"name", "tag-label", "tag-value", "metric"} {
+			if cmd.Flags().Lookup(name) != nil {
+				cmd.Flags().Set(name, "")
+			}
+		}
+		for name, value := range flags {
+			if err := cmd.Flags().Set(name, value); err != nil {
+				t.Fatalf("expected no error setting --%s, got %v", name, err)
+			}
+		}
+	}
+	tests := []struct {
+		name     string
+		pushCmd  *cobra.Command
+		flags    map[string]string
+		expected string
+	}{
+		{"push", metricsPushCmd, map[string]string{}, "/metrics/job/obs-pusher"},
+		{"push with tags", metricsPushCmd, map[string]string{"name": "checkout", "tag-label": "env", "tag-value": "staging"}, "/metrics/job/checkout/env/staging"},
+		{"push-from", metricsPushDictionaryCmd, map[string]string{"metric": "application.part.proctime", "tag-value": "eu"}, "/metrics/job/application/region/eu"},
+	}
+	for _, test := range tests {
+		name := test.name
+		test.flags["pushgateway-url"] = server.URL
+		setFlags(test.pushCmd, test.flags)
+		setFlags(metricsClearCmd, test.flags)
+
+		defaults := pushSinkDefaults(test.pushCmd)
+		if test.pushCmd == metricsPushDictionaryCmd {
+			defaults = dictionarySinkDefaults(&sources.Metric{Name: "application.part.proctime", Tags: "region=value"}, test.flags["tag-value"])
+		}
+		pushgateway, err := newPushgateway(test.pushCmd, defaults)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		if err := pushgateway.Send(context.Background(), nil, time.Now()); err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		cleared, err := clearedPushgateway(metricsClearCmd)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+		if err := cleared.Delete(context.Background()); err != nil {
+			t.Fatalf("%s: expected no error, got %v", name, err)
+		}
+
+		if push, clear := <-requests, <-requests; push != "PUT "+test.expected || clear != "DELETE "+test.expected {
+			t.Errorf("%s: expected PUT then DELETE on %s, got %q then %q", name, test.expected, push, clear)
+		}
+	}
+}
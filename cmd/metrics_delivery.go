@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/exporter"
+	"github.com/Patrick-Ivann/observability-pusher/internal/sinks"
+	"github.com/Patrick-Ivann/observability-pusher/internal/sources"
+	"github.com/spf13/cobra"
+)
+
+// Delivery modes of the metrics push commands
+const (
+	// modeScrape serves the metrics from a pod scraped through a ServiceMonitor
+	modeScrape = "scrape"
+	// modeRemoteWrite sends the metrics to a remote-write endpoint from the CLI
+	modeRemoteWrite = "remote-write"
+	// modePushgateway pushes the metrics to a Pushgateway group from the CLI
+	modePushgateway = "pushgateway"
+	// modeOTLP exports the metrics to an OpenTelemetry collector from the CLI
+	modeOTLP = "otlp"
+	// modeStatsD sends the metrics to a StatsD server from the CLI
+	modeStatsD = "statsd"
+	// modeInflux writes the metrics to InfluxDB from the CLI
+	modeInflux = "influx"
+	// modeGraphite sends the metrics to a Graphite carbon receiver from the CLI
+	modeGraphite = "graphite"
+)
+
+// sinkDefaults are derived from the pushed metric, the delivery flags override them
+type sinkDefaults struct {
+	// job is the Pushgateway job and the OTLP service name
+	job string
+	// grouping labels of the Pushgateway group
+	grouping map[string]string
+	// paths maps the metric names to the dotted dictionary names, used as
+	// Graphite paths and InfluxDB measurements and fields
+	paths map[string]string
+}
+
+// pushSinkDefaults returns the sink defaults of the metric of metrics push,
+// the job being --name and the grouping the --tag-label and --tag-value label
+func pushSinkDefaults(cmd *cobra.Command) sinkDefaults {
+	applicationName, _ := cmd.Flags().GetString("name")
+	metricTagLabel, _ := cmd.Flags().GetString("tag-label")
+	metricTagValue, _ := cmd.Flags().GetString("tag-value")
+
+	defaults := sinkDefaults{job: applicationName, grouping: metricTagLabels(metricTagLabel, metricTagValue)}
+	if defaults.job == "" {
+		defaults.job = "obs-pusher"
+	}
+	return defaults
+}
+
+// dictionarySinkDefaults returns the sink defaults of a dictionary metric of
+// metrics push-from, the job being the first part of its name and the
+// grouping its tags
+func dictionarySinkDefaults(metric *sources.Metric, metricTagValue string) sinkDefaults {
+	return sinkDefaults{
+		job:      strings.Split(metric.Name, ".")[0],
+		grouping: dictionaryMetricLabels(metric, metricTagValue),
+		paths:    map[string]string{metric.FullyQualifiedName: metric.Name},
+	}
+}
+
+// addDeliveryFlags registers the flags choosing how metrics reach the backend
+func addDeliveryFlags(cmd *cobra.Command) {
+	cmd.Flags().String("mode", modeScrape, "How metrics are delivered: scrape (exporter pod and ServiceMonitor), or remote-write, pushgateway, otlp, statsd, influx or graphite (sent from the CLI, no cluster needed)")
+	cmd.Flags().Float64("push-interval", 15, "Interval in seconds between two sends when the metrics are sent from the CLI")
+	cmd.Flags().Float64("duration", 0, "Seconds to keep sending for when the metrics are sent from the CLI, until interrupted when 0")
+	cmd.Flags().Bool("once", false, "Send the metrics a single time after --duration seconds, as a batch job pushing its results when it ends")
+	cmd.Flags().String("remote-write-url", "", "Remote-write endpoint e.g 'http://prometheus:9090/api/v1/write' or 'http://mimir/api/v1/push'")
+	cmd.Flags().String("tenant-id", "", "Tenant sent as the X-Scope-OrgID header, for Mimir, Cortex or Thanos")
+	addPushgatewayFlags(cmd)
+	cmd.Flags().String("otlp-endpoint", "", "OTLP endpoint e.g 'http://collector:4318' for http/protobuf or 'collector:4317' for grpc, 'https://' enables TLS")
+	cmd.Flags().String("otlp-protocol", sinks.OTLPProtocolHTTP, "OTLP transport: grpc or http/protobuf")
+	cmd.Flags().String("resource-attributes", "", `OTLP resource attributes as "key:value,anotherkey:anothervalue", service.name defaults to the application name`)
+	cmd.Flags().String("statsd-address", "127.0.0.1:8125", "StatsD server address as host:port")
+	cmd.Flags().String("statsd-protocol", "udp", "StatsD transport: udp or tcp")
+	cmd.Flags().Bool("dogstatsd", false, "Use the DogStatsD extensions, labels sent as tags and histograms as DogStatsD histograms")
+	cmd.Flags().String("influx-url", "", "InfluxDB address e.g 'http://influxdb:8086'")
+	cmd.Flags().String("influx-org", "", "InfluxDB organization")
+	cmd.Flags().String("influx-bucket", "", "InfluxDB bucket, database/retention-policy on InfluxDB 1.x")
+	cmd.Flags().String("influx-token", "", "InfluxDB API token, user:password on InfluxDB 1.x")
+	cmd.Flags().String("graphite-address", "127.0.0.1:2003", "Graphite carbon plaintext receiver address as host:port")
+	cmd.Flags().String("graphite-prefix", "", "Prefix of the Graphite paths e.g 'obs-pusher.staging'")
+	cmd.Flags().String("pushgateway-method", "PUT", "PUT replaces the whole Pushgateway group, POST only the pushed metrics")
+	cmd.Flags().StringArray("header", nil, "HTTP header added to the requests as Name=Value, repeatable e.g '--header=Authorization=Bearer <token>'")
+}
+
+// addPushgatewayFlags registers the flags identifying a Pushgateway group
+func addPushgatewayFlags(cmd *cobra.Command) {
+	cmd.Flags().String("pushgateway-url", "", "Pushgateway address e.g 'http://pushgateway:9091'")
+	cmd.Flags().String("job", "", "Pushgateway job, defaults to the application name")
+	cmd.Flags().String("grouping", "", `Pushgateway grouping labels as "key:value,anotherkey:anothervalue", default to the metric tags`)
+}
+
+// newPushgateway builds the Pushgateway sink from the flags
+func newPushgateway(cmd *cobra.Command, defaults sinkDefaults) (*sinks.Pushgateway, error) {
+	pushgatewayURL, _ := cmd.Flags().GetString("pushgateway-url")
+	job, _ := cmd.Flags().GetString("job")
+	rawGrouping, _ := cmd.Flags().GetString("grouping")
+	rawHeaders, _ := cmd.Flags().GetStringArray("header")
+	if pushgatewayURL == "" {
+		return nil, fmt.Errorf("--pushgateway-url is required to push to the pushgateway")
+	}
+	if job == "" {
+		job = defaults.job
+	}
+	grouping := Labels(defaults.grouping)
+	if rawGrouping != "" {
+		grouping = Labels{}
+		if err := grouping.Set(rawGrouping); err != nil {
+			return nil, err
+		}
+	}
+	headers, err := parseHeaders(rawHeaders)
+	if err != nil {
+		return nil, err
+	}
+	return &sinks.Pushgateway{URL: pushgatewayURL, Job: job, Grouping: grouping, Headers: headers}, nil
+}
+
+// parseHeaders parses headers given as Name=Value
+func parseHeaders(rawHeaders []string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, rawHeader := range rawHeaders {
+		name, value, found := strings.Cut(rawHeader, "=")
+		if !found || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header format %q, expected Name=Value", rawHeader)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// newSink builds the sink of the delivery mode from the flags
+func newSink(cmd *cobra.Command, mode string, defaults sinkDefaults) (sinks.Sink, error) {
+	rawHeaders, _ := cmd.Flags().GetStringArray("header")
+	headers, err := parseHeaders(rawHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	switch mode {
+	case modeRemoteWrite:
+		url, _ := cmd.Flags().GetString("remote-write-url")
+		tenantID, _ := cmd.Flags().GetString("tenant-id")
+		if url == "" {
+			return nil, fmt.Errorf("--remote-write-url is required in remote-write mode")
+		}
+		return &sinks.RemoteWrite{URL: url, Headers: headers, TenantID: tenantID}, nil
+	case modePushgateway:
+		pushgateway, err := newPushgateway(cmd, defaults)
+		if err != nil {
+			return nil, err
+		}
+		method, _ := cmd.Flags().GetString("pushgateway-method")
+		pushgateway.Method = strings.ToUpper(method)
+		if pushgateway.Method != http.MethodPut && pushgateway.Method != http.MethodPost {
+			return nil, fmt.Errorf("--pushgateway-method must be PUT or POST, got %s", method)
+		}
+		return pushgateway, nil
+	case modeOTLP:
+		endpoint, _ := cmd.Flags().GetString("otlp-endpoint")
+		protocol, _ := cmd.Flags().GetString("otlp-protocol")
+		rawAttributes, _ := cmd.Flags().GetString("resource-attributes")
+		if endpoint == "" {
+			return nil, fmt.Errorf("--otlp-endpoint is required in otlp mode")
+		}
+		if protocol != sinks.OTLPProtocolGRPC && protocol != sinks.OTLPProtocolHTTP {
+			return nil, fmt.Errorf("--otlp-protocol must be %s or %s, got %s", sinks.OTLPProtocolGRPC, sinks.OTLPProtocolHTTP, protocol)
+		}
+		attributes := Labels{"service.name": defaults.job}
+		if rawAttributes != "" {
+			if err := attributes.Set(rawAttributes); err != nil {
+				return nil, err
+			}
+		}
+		return &sinks.OTLP{Endpoint: endpoint, Protocol: protocol, ResourceAttributes: attributes, Headers: headers}, nil
+	case modeStatsD:
+		address, _ := cmd.Flags().GetString("statsd-address")
+		protocol, _ := cmd.Flags().GetString("statsd-protocol")
+		dogStatsD, _ := cmd.Flags().GetBool("dogstatsd")
+		if protocol != "udp" && protocol != "tcp" {
+			return nil, fmt.Errorf("--statsd-protocol must be udp or tcp, got %s", protocol)
+		}
+		return &sinks.StatsD{Address: address, Network: protocol, DogStatsD: dogStatsD, Paths: defaults.paths}, nil
+	case modeInflux:
+		url, _ := cmd.Flags().GetString("influx-url")
+		org, _ := cmd.Flags().GetString("influx-org")
+		bucket, _ := cmd.Flags().GetString("influx-bucket")
+		token, _ := cmd.Flags().GetString("influx-token")
+		if url == "" || bucket == "" {
+			return nil, fmt.Errorf("--influx-url and --influx-bucket are required in influx mode")
+		}
+		return &sinks.Influx{URL: url, Org: org, Bucket: bucket, Token: token, Paths: defaults.paths, Headers: headers}, nil
+	case modeGraphite:
+		address, _ := cmd.Flags().GetString("graphite-address")
+		prefix, _ := cmd.Flags().GetString("graphite-prefix")
+		return &sinks.Graphite{Address: address, Prefix: prefix, Paths: defaults.paths}, nil
+	default:
+		return nil, fmt.Errorf("unknown delivery mode %q, expected %s", mode, strings.Join([]string{modeScrape, modeRemoteWrite, modePushgateway, modeOTLP, modeStatsD, modeInflux, modeGraphite}, ", "))
+	}
+}
+
+// pushToSink sends the metrics of the configuration from the CLI, every push
+// interval until the duration elapsed or the command is interrupted
+func pushToSink(cmd *cobra.Command, mode string, config exporter.Config, defaults sinkDefaults) error {
+	sink, err := newSink(cmd, mode, defaults)
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	pushInterval, _ := cmd.Flags().GetFloat64("push-interval")
+	duration, _ := cmd.Flags().GetFloat64("duration")
+	once, _ := cmd.Flags().GetBool("once")
+	if pushInterval <= 0 {
+		return fmt.Errorf("--push-interval must be positive, got %v", pushInterval)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if once {
+		fmt.Printf("sending metrics once in %s mode in %vs\n", mode, duration)
+		return sinks.SendOnce(ctx, config, sink, seconds(duration))
+	}
+	fmt.Printf("sending metrics in %s mode every %vs\n", mode, pushInterval)
+	return sinks.Run(ctx, config, sink, seconds(pushInterval), seconds(duration))
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
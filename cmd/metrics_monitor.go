@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/kubernetes"
+	"github.com/Patrick-Ivann/observability-pusher/internal/sources"
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// How Prometheus discovers the metric pods
+const (
+	// monitorServiceMonitor creates a Service and a ServiceMonitor selecting it
+	monitorServiceMonitor = "servicemonitor"
+	// monitorPodMonitor creates a PodMonitor selecting the pod
+	monitorPodMonitor = "podmonitor"
+	// monitorNone only creates the pod, scraping is left to the user
+	monitorNone = "none"
+	// monitorAnnotations annotates the pod for the classic prometheus.io/scrape configuration
+	monitorAnnotations = "annotations"
+)
+
+// addMonitorKindFlag registers the flag choosing how the metric pods are discovered
+func addMonitorKindFlag(cmd *cobra.Command) {
+	cmd.Flags().String("monitor-kind", monitorServiceMonitor, "How Prometheus discovers the exporter: servicemonitor (Service and ServiceMonitor), podmonitor, annotations (prometheus.io/scrape pod annotations) or none")
+}
+
+// parseMonitorKindFlag returns the monitor kind given on the command line
+func parseMonitorKindFlag(cmd *cobra.Command) (string, error) {
+	monitorKind, _ := cmd.Flags().GetString("monitor-kind")
+	switch monitorKind {
+	case monitorServiceMonitor, monitorPodMonitor, monitorNone, monitorAnnotations:
+		return monitorKind, nil
+	default:
+		return "", fmt.Errorf("unknown monitor kind %q, expected %s, %s, %s or %s", monitorKind, monitorServiceMonitor, monitorPodMonitor, monitorAnnotations, monitorNone)
+	}
+}
+
+// addScrapeFlags registers the flags configuring the ServiceMonitor or PodMonitor endpoint
+func addScrapeFlags(cmd *cobra.Command) {
+	cmd.Flags().String("scrape-interval", "", "Scrape interval of the monitor e.g '15s', the Prometheus global interval when empty")
+	cmd.Flags().String("scrape-timeout", "", "Scrape timeout of the monitor e.g '10s', no longer than the interval")
+	cmd.Flags().Bool("honor-labels", false, "Keep the labels of the series colliding with the target labels")
+	cmd.Flags().String("metric-relabelings", "", `Metric relabelings of the monitor as a YAML or JSON list e.g '[{action: drop, sourceLabels: [__name__], regex: "go_.*"}]'`)
+	cmd.Flags().String("relabelings", "", "Target relabelings of the monitor as a YAML or JSON list")
+	cmd.Flags().Uint64("sample-limit", 0, "Fail the scrapes returning more samples than this, no limit when 0")
+	cmd.Flags().String("scrape-scheme", "", "Scheme of the scrapes, only http as the exporter pods do not serve TLS yet")
+	cmd.Flags().String("tls-server-name", "", "Server name checked against the target certificate")
+	cmd.Flags().Bool("tls-insecure-skip-verify", false, "Skip the verification of the target certificate")
+	cmd.Flags().String("tls-ca-secret", "", "Secret holding the CA of the target certificate as name:key")
+	cmd.Flags().String("monitor-labels", "", `Extra labels of the monitor e.g to match the Prometheus serviceMonitorSelector, as "key:value,anotherkey:anothervalue"`)
+}
+
+// parseScrapeFlags returns the monitor endpoint settings given on the command line
+func parseScrapeFlags(cmd *cobra.Command) (kubernetes.ScrapeSettings, error) {
+	interval, _ := cmd.Flags().GetString("scrape-interval")
+	timeout, _ := cmd.Flags().GetString("scrape-timeout")
+	honorLabels, _ := cmd.Flags().GetBool("honor-labels")
+	rawMetricRelabelings, _ := cmd.Flags().GetString("metric-relabelings")
+	rawRelabelings, _ := cmd.Flags().GetString("relabelings")
+	sampleLimit, _ := cmd.Flags().GetUint64("sample-limit")
+	scheme, _ := cmd.Flags().GetString("scrape-scheme")
+	serverName, _ := cmd.Flags().GetString("tls-server-name")
+	insecureSkipVerify, _ := cmd.Flags().GetBool("tls-insecure-skip-verify")
+	caSecret, _ := cmd.Flags().GetString("tls-ca-secret")
+	rawMonitorLabels, _ := cmd.Flags().GetString("monitor-labels")
+
+	scrape := sources.Scrape{
+		Interval:      interval,
+		ScrapeTimeout: timeout,
+		HonorLabels:   honorLabels,
+		Scheme:        scheme,
+	}
+	if err := yaml.Unmarshal([]byte(rawMetricRelabelings), &scrape.MetricRelabelings); err != nil {
+		return kubernetes.ScrapeSettings{}, fmt.Errorf("invalid --metric-relabelings: %w", err)
+	}
+	if err := yaml.Unmarshal([]byte(rawRelabelings), &scrape.Relabelings); err != nil {
+		return kubernetes.ScrapeSettings{}, fmt.Errorf("invalid --relabelings: %w", err)
+	}
+	if sampleLimit > 0 {
+		scrape.SampleLimit = &sampleLimit
+	}
+	if serverName != "" || insecureSkipVerify || caSecret != "" {
+		scrape.TLS = &sources.ScrapeTLS{ServerName: serverName, InsecureSkipVerify: insecureSkipVerify, CASecret: caSecret}
+	}
+	if rawMonitorLabels != "" {
+		monitorLabels := Labels{}
+		if err := monitorLabels.Set(rawMonitorLabels); err != nil {
+			return kubernetes.ScrapeSettings{}, err
+		}
+		scrape.Labels = monitorLabels
+	}
+	return scrapeSettings(scrape)
+}
+
+// scrapeSettings converts the scrape settings of the flags or of a scenario group
+// to the monitor endpoint settings
+func scrapeSettings(scrape sources.Scrape) (kubernetes.ScrapeSettings, error) {
+	settings := kubernetes.ScrapeSettings{
+		Interval:          monitoringv1.Duration(scrape.Interval),
+		ScrapeTimeout:     monitoringv1.Duration(scrape.ScrapeTimeout),
+		HonorLabels:       scrape.HonorLabels,
+		MetricRelabelings: relabelConfigs(scrape.MetricRelabelings),
+		Relabelings:       relabelConfigs(scrape.Relabelings),
+		SampleLimit:       scrape.SampleLimit,
+		Scheme:            scrape.Scheme,
+		Labels:            scrape.Labels,
+	}
+	if scrape.TLS != nil {
+		settings.TLSConfig = &monitoringv1.SafeTLSConfig{}
+		if scrape.TLS.ServerName != "" {
+			settings.TLSConfig.ServerName = &scrape.TLS.ServerName
+		}
+		if scrape.TLS.InsecureSkipVerify {
+			settings.TLSConfig.InsecureSkipVerify = &scrape.TLS.InsecureSkipVerify
+		}
+		if scrape.TLS.CASecret != "" {
+			secretName, key, found := strings.Cut(scrape.TLS.CASecret, ":")
+			if !found {
+				return settings, fmt.Errorf("invalid TLS CA secret %q, expected name:key", scrape.TLS.CASecret)
+			}
+			settings.TLSConfig.CA.Secret = &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: secretName}, Key: key}
+		}
+	}
+	return settings, settings.Validate()
+}
+
+func relabelConfigs(relabelings []sources.Relabeling) []monitoringv1.RelabelConfig {
+	var configs []monitoringv1.RelabelConfig
+	for _, relabeling := range relabelings {
+		config := monitoringv1.RelabelConfig{
+			Separator:   relabeling.Separator,
+			TargetLabel: relabeling.TargetLabel,
+			Regex:       relabeling.Regex,
+			Modulus:     relabeling.Modulus,
+			Replacement: relabeling.Replacement,
+			Action:      relabeling.Action,
+		}
+		for _, sourceLabel := range relabeling.SourceLabels {
+			config.SourceLabels = append(config.SourceLabels, monitoringv1.LabelName(sourceLabel))
+		}
+		configs = append(configs, config)
+	}
+	return configs
+}
@@ -1,8 +1,8 @@
 package cmd
 
 import (
-	"fmt"
-
+	"github.com/Patrick-Ivann/observability-pusher/internal/exporter"
+	"github.com/Patrick-Ivann/observability-pusher/internal/generator"
 	"github.com/Patrick-Ivann/observability-pusher/internal/kubernetes"
 
 	"github.com/spf13/cobra"
@@ -13,120 +13,256 @@ func init() {
 	metricsPushCmd.Flags().String("namespace", "test", "Namespace to create resources in")
 	metricsPushCmd.Flags().String("name", "", "Name of producing app")
 	metricsPushCmd.Flags().String("metric", "", "Name of the metric to push")
-	metricsPushCmd.Flags().Int("value", 0, "Value of the metric to push")
+	metricsPushCmd.Flags().Float64("value", 0, "Value of the metric to push, accepts decimals, scientific notation, NaN and +Inf/-Inf, counters are incremented by it and histograms and summaries observe it every second")
+	metricsPushCmd.Flags().String("type", "gauge", "Type of the metric to push: gauge, counter, histogram, summary or untyped")
+	metricsPushCmd.Flags().Float64Slice("buckets", nil, "Histogram bucket upper bounds e.g '--buckets=0.1,0.5,1', defaults to the Prometheus default buckets")
+	metricsPushCmd.Flags().Float64Slice("quantiles", nil, "Summary quantiles e.g '--quantiles=0.5,0.9,0.99'")
+	metricsPushCmd.Flags().String("generator", generator.KindConstant, "How the value evolves over time: constant, ramp, sine, random-walk, step or spike, --value is the starting point")
+	metricsPushCmd.Flags().String("generator-params", "", "Generator parameters e.g '--generator-params=amplitude=10,period=60', any of slope, amplitude, period, min, max, step, at, to, duration, seed")
+	metricsPushCmd.Flags().String("unit", "", "Unit of the metric e.g seconds or bytes, exposed in the OpenMetrics format and appended to the name when missing")
+	metricsPushCmd.Flags().Bool("exemplars", false, "Attach exemplars with random trace IDs to counter and histogram samples, exposed in the OpenMetrics format")
+	metricsPushCmd.Flags().Bool("exponential", false, "Add exponential buckets to histograms, exposed as a native histogram and exported as an OTLP exponential histogram")
+	addLabelSetFlags(metricsPushCmd)
+	addCardinalityFlags(metricsPushCmd)
+	addFaultFlags(metricsPushCmd)
+	addLifecycleFlags(metricsPushCmd)
+	addDeliveryFlags(metricsPushCmd)
 	metricsPushCmd.Flags().String("tag-value", "", "")
 	metricsPushCmd.Flags().String("tag-label", "", "")
+	addMonitorKindFlag(metricsPushCmd)
+	addScrapeFlags(metricsPushCmd)
 	metricsPushCmd.Flags().Bool("psa-enabled", false, "if the cluster has some Pod Security Admission enabled")
 	metricsPushCmd.Flags().String("service-account", "default", "Name of the ServiceAccount to use")
 	// metricsPushCmd.Flags().Var(&podLabels, "pod-labels", `Specify labels as "key:value,anotherkey:anothervalue"`)
 
 }
 
-func generateMetricCommand(metricName string, metricValue int16, metricTagLabel string, metricTagValue string) string {
-	if metricTagLabel != "" && metricTagValue != "" {
-		return fmt.Sprintf(`while true; do
-        echo "# HELP %s A custom gauge metric" > /usr/share/nginx/html/metrics;
-        echo "# TYPE %s gauge" >> /usr/share/nginx/html/metrics;
-        echo "%s{%s=\"%s\"} %d" >> /usr/share/nginx/html/metrics;
-        sleep 5;
-        done`, metricName, metricName, metricName, metricTagLabel, metricTagValue, metricValue)
+// parseGeneratorFlags returns the generator spec described by the --generator and --generator-params flags
+func parseGeneratorFlags(cmd *cobra.Command) (*generator.Spec, error) {
+	kind, _ := cmd.Flags().GetString("generator")
+	params, _ := cmd.Flags().GetString("generator-params")
+	if (kind == "" || kind == generator.KindConstant) && params == "" {
+		return nil, nil
+	}
+	spec, err := generator.ParseSpec(kind, params)
+	if err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// addCardinalityFlags registers the flags of the cardinality explosion mode
+func addCardinalityFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("cardinality", 0, "Generate this many series differing by a high cardinality label, disabled when 0")
+	cmd.Flags().String("cardinality-label", "id", "Name of the high cardinality label")
+	cmd.Flags().String("cardinality-values", exporter.CardinalityIncrementing, "Kind of high cardinality label values: incrementing or uuid")
+	cmd.Flags().Float64("churn-interval", 0, "Interval in seconds between two replacements of high cardinality series, no churn when 0")
+	cmd.Flags().Float64("churn-rate", 0.1, "Fraction of the high cardinality series replaced every churn interval")
+}
+
+// parseCardinalityFlags returns the cardinality settings given on the command line, nil when disabled
+func parseCardinalityFlags(cmd *cobra.Command) *exporter.Cardinality {
+	seriesCount, _ := cmd.Flags().GetInt("cardinality")
+	if seriesCount == 0 {
+		return nil
+	}
+	label, _ := cmd.Flags().GetString("cardinality-label")
+	values, _ := cmd.Flags().GetString("cardinality-values")
+	churnInterval, _ := cmd.Flags().GetFloat64("churn-interval")
+	churnRate, _ := cmd.Flags().GetFloat64("churn-rate")
+	return &exporter.Cardinality{Series: seriesCount, Label: label, Values: values, ChurnInterval: churnInterval, ChurnRate: churnRate}
+}
+
+// parseWindows parses windows given as start:duration[:every]
+func parseWindows(rawWindows []string) ([]exporter.Window, error) {
+	var windows []exporter.Window
+	for _, rawWindow := range rawWindows {
+		window, err := exporter.ParseWindow(rawWindow)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// addLifecycleFlags registers the flags resetting counters and hiding series
+func addLifecycleFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64Slice("reset-at", nil, "Times in seconds after the pod started at which counters, histograms and summaries are reset, simulating a restart")
+	cmd.Flags().Float64("reset-every", 0, "Reset counters, histograms and summaries with this period in seconds, simulating restarts")
+	cmd.Flags().StringArray("absent", nil, "Window during which the series disappears as start:duration[:every] in seconds after the pod started, repeatable e.g '--absent=60:30'")
+}
+
+// addFaultFlags registers the flags injecting faults on the /metrics endpoint
+func addFaultFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64("fault-error-rate", 0, "Fraction of scrapes answered with --fault-error-status")
+	cmd.Flags().Int("fault-error-status", 500, "HTTP status of the failed scrapes")
+	cmd.Flags().Float64("fault-delay", 0, "Delay in seconds before answering a scrape, e.g beyond the scrape timeout")
+	cmd.Flags().Float64("fault-delay-rate", 1, "Fraction of scrapes delayed by --fault-delay")
+	cmd.Flags().Float64("fault-truncate-rate", 0, "Fraction of scrapes whose exposition is cut in the middle")
+	cmd.Flags().Float64("fault-malformed-rate", 0, "Fraction of scrapes whose exposition contains an unparsable line")
+	cmd.Flags().StringArray("fault-downtime", nil, "Window during which the endpoint is down as start:duration[:every] in seconds after the pod started, repeatable e.g '--fault-downtime=60:30:300'")
+}
+
+// parseFaultFlags returns the faults given on the command line, nil when none are set
+func parseFaultFlags(cmd *cobra.Command) (*exporter.Faults, error) {
+	errorRate, _ := cmd.Flags().GetFloat64("fault-error-rate")
+	errorStatus, _ := cmd.Flags().GetInt("fault-error-status")
+	delay, _ := cmd.Flags().GetFloat64("fault-delay")
+	delayRate, _ := cmd.Flags().GetFloat64("fault-delay-rate")
+	truncateRate, _ := cmd.Flags().GetFloat64("fault-truncate-rate")
+	malformedRate, _ := cmd.Flags().GetFloat64("fault-malformed-rate")
+	rawDowntimes, _ := cmd.Flags().GetStringArray("fault-downtime")
+
+	downtimes, err := parseWindows(rawDowntimes)
+	if err != nil {
+		return nil, err
 	}
 
-	return fmt.Sprintf(`while true; do
-    echo "# HELP %s A custom gauge metric" > /usr/share/nginx/html/metrics;
-    echo "# TYPE %s gauge" >> /usr/share/nginx/html/metrics;
-    echo "%s %d" >> /usr/share/nginx/html/metrics;
-    sleep 5;
-    done`, metricName, metricName, metricName, metricValue)
+	if errorRate == 0 && delay == 0 && truncateRate == 0 && malformedRate == 0 && len(downtimes) == 0 {
+		return nil, nil
+	}
+	return &exporter.Faults{
+		ErrorRate:     errorRate,
+		ErrorStatus:   errorStatus,
+		Delay:         delay,
+		DelayRate:     delayRate,
+		TruncateRate:  truncateRate,
+		MalformedRate: malformedRate,
+		Downtime:      downtimes,
+	}, nil
+}
+
+func generateMetricConfig(series exporter.Series, metricTagLabel string, metricTagValue string) exporter.Config {
+	if labels := metricTagLabels(metricTagLabel, metricTagValue); labels != nil {
+		series.Labels = labels
+	}
+	return exporter.Config{Series: []exporter.Series{series}}
+}
+
+// metricTagLabels returns the label of the --tag-label and --tag-value flags, none when one is empty
+func metricTagLabels(metricTagLabel string, metricTagValue string) map[string]string {
+	if metricTagLabel == "" || metricTagValue == "" {
+		return nil
+	}
+	return map[string]string{metricTagLabel: metricTagValue}
 }
 
 // metricsPushCmd represents the push command for metrics
 var metricsPushCmd = &cobra.Command{
 	Use:   "push",
 	Short: "Push a metric",
-	Long:  "Push a metric --namespace=<> --element=<> --metric=<> --value=<> --tag=<> --label=<> --pod-labels=key:value,anotherkey:anothervalue",
+	Long:  "Push a metric --namespace=<> --element=<> --metric=<> --value=<> --type=<> --tag=<> --label=<> --pod-labels=key:value,anotherkey:anothervalue",
 
 	Run: func(cmd *cobra.Command, args []string) {
 		namespace, _ := cmd.Flags().GetString("namespace")
 		applicationName, _ := cmd.Flags().GetString("name")
 		metricName, _ := cmd.Flags().GetString("metric")
-		metricValue, _ := cmd.Flags().GetInt("value")
+		metricValue, _ := cmd.Flags().GetFloat64("value")
+		metricType, _ := cmd.Flags().GetString("type")
+		buckets, _ := cmd.Flags().GetFloat64Slice("buckets")
+		quantiles, _ := cmd.Flags().GetFloat64Slice("quantiles")
+		unit, _ := cmd.Flags().GetString("unit")
+		exemplars, _ := cmd.Flags().GetBool("exemplars")
+		exponential, _ := cmd.Flags().GetBool("exponential")
 		metricTagValue, _ := cmd.Flags().GetString("tag-value")
 		metricTagLabel, _ := cmd.Flags().GetString("tag-label")
 		isPsaEnabled, _ := cmd.Flags().GetBool("psa-enabled")
 		registry, _ := cmd.Flags().GetString("registry-path")
 		registryPullSecret, _ := cmd.Flags().GetString("image-pull-secret")
 		serviceAccount, _ := cmd.Flags().GetString("service-account")
-		knImpl, err := kubernetes.NewClientset(registry, registryPullSecret, serviceAccount)
+		valueGenerator, err := parseGeneratorFlags(cmd)
 		if err != nil {
 			println(err.Error())
 			return
 		}
-
-		// check if namespace exists
-		isNamespaceExisting, err := knImpl.IsNamespaceExisting(namespace)
+		labelSets, labelValues, err := parseLabelSetFlags(cmd)
 		if err != nil {
 			println(err.Error())
 			return
 		}
-		// create namespace
-		if !isNamespaceExisting {
-			knImpl.CreateNamespace(namespace)
+		faults, err := parseFaultFlags(cmd)
+		if err != nil {
+			println(err.Error())
+			return
 		}
-
-		// Check if pod exists by fetching it based on labels
-		services, err := knImpl.FetchServiceByLabels(namespace, Labels{"obs-pusher": "metrics"})
+		resetAt, _ := cmd.Flags().GetFloat64Slice("reset-at")
+		resetEvery, _ := cmd.Flags().GetFloat64("reset-every")
+		rawAbsent, _ := cmd.Flags().GetStringArray("absent")
+		absent, err := parseWindows(rawAbsent)
 		if err != nil {
 			println(err.Error())
 			return
 		}
 
-		// delete existing pod if it exists
-		if len(services.Items) > 0 {
-			for _, pod := range services.Items {
-				knImpl.DeleteService(namespace, pod.Name)
-			}
+		series := exporter.Series{
+			Name:        metricName,
+			Type:        metricType,
+			LabelSets:   labelSets,
+			LabelValues: labelValues,
+			Value:       exporter.Float(metricValue),
+			Buckets:     buckets,
+			Quantiles:   quantiles,
+			Unit:        unit,
+			Generator:   valueGenerator,
+			Cardinality: parseCardinalityFlags(cmd),
+			ResetAt:     resetAt,
+			ResetEvery:  resetEvery,
+			Absent:      absent,
+			Exemplars:   exemplars,
+			Exponential: exponential,
 		}
+		config := generateMetricConfig(series, metricTagLabel, metricTagValue)
+		config.Faults = faults
 
-		// Create service with specific name and namespace
-		knImpl.CreateService(namespace, applicationName, Labels{"obs-pusher": "metrics"})
+		// Metrics sent from the CLI do not need the cluster
+		mode, _ := cmd.Flags().GetString("mode")
+		if mode != modeScrape {
+			if err := pushToSink(cmd, mode, config, pushSinkDefaults(cmd)); err != nil {
+				println(err.Error())
+			}
+			return
+		}
 
-		// Check if pod exists by fetching it based on labels
-		servicemonitors, err := knImpl.FetchServiceMonitorByLabels(namespace, Labels{"obs-pusher": "metrics"})
+		// Generate exporter arguments based on provided tags and values
+		exporterArgs, err := serveMetricsArgs(config)
 		if err != nil {
 			println(err.Error())
 			return
 		}
-
-		// delete existing pod if it exists
-		if len(servicemonitors.Items) > 0 {
-			for _, pod := range servicemonitors.Items {
-				knImpl.DeleteServiceMonitor(namespace, pod.Name)
-			}
+		monitorKind, err := parseMonitorKindFlag(cmd)
+		if err != nil {
+			println(err.Error())
+			return
+		}
+		scrapeSettings, err := parseScrapeFlags(cmd)
+		if err != nil {
+			println(err.Error())
+			return
 		}
 
-		knImpl.CreateServiceMonitor(namespace, applicationName, Labels{"obs-pusher": "metrics"})
-
-		// Use existing serviceMonitor
-
-		// Check if pod exists by fetching it based on labels
-		podList, err := knImpl.FetchPodByLabels(namespace, Labels{"obs-pusher": "metrics"})
+		knImpl, err := kubernetes.NewClientset(registry, registryPullSecret, serviceAccount)
 		if err != nil {
 			println(err.Error())
 			return
 		}
 
-		// delete existing pod if it exists
-		if len(podList.Items) > 0 {
-			for _, pod := range podList.Items {
-				knImpl.DeletePod(namespace, pod.Name)
-				knImpl.WaitForPodDeletion(namespace, pod.Name)
-			}
+		// check if namespace exists
+		isNamespaceExisting, err := knImpl.IsNamespaceExisting(namespace)
+		if err != nil {
+			println(err.Error())
+			return
+		}
+		// create namespace
+		if !isNamespaceExisting {
+			knImpl.CreateNamespace(namespace)
 		}
 
-		// Generate metric command based on provided tags and values
-		metricCommand := generateMetricCommand(metricName, int16(metricValue), metricTagLabel, metricTagValue)
-		knImpl.CreateMetricPod(namespace, applicationName, []string{"/bin/sh", "-c", metricCommand}, podLabels, isPsaEnabled)
+		err = replaceMetricExporter(knImpl, namespace, applicationName, Labels{"obs-pusher": "metrics"}, podLabels, exporterArgs, isPsaEnabled, monitorKind, scrapeSettings)
+		if err != nil {
+			println(err.Error())
+			return
+		}
 
 	},
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/Patrick-Ivann/observability-pusher/internal/exporter"
 	"github.com/Patrick-Ivann/observability-pusher/internal/kubernetes"
 	"github.com/Patrick-Ivann/observability-pusher/internal/sources"
 
@@ -14,13 +15,54 @@ func init() {
 	podLabels = Labels{"obs-pusher": "metrics"} // Initialize with default label
 
 	metricsPushDictionaryCmd.Flags().String("metric", "", "Name of the metric to push")
-	metricsPushDictionaryCmd.Flags().Int("value", 0, "Value of the metric to push")
+	metricsPushDictionaryCmd.Flags().Float64("value", 0, "Value of the metric to push, accepts decimals, scientific notation, NaN and +Inf/-Inf, counters are incremented by it and histograms and summaries observe it every second")
+	metricsPushDictionaryCmd.Flags().String("type", "", "Override the Prometheus type derived from the dictionary type: gauge, counter, histogram, summary or untyped")
+	metricsPushDictionaryCmd.Flags().Float64Slice("buckets", nil, "Histogram bucket upper bounds e.g '--buckets=0.1,0.5,1', defaults to the Prometheus default buckets")
+	metricsPushDictionaryCmd.Flags().Float64Slice("quantiles", nil, "Summary quantiles e.g '--quantiles=0.5,0.9,0.99'")
+	metricsPushDictionaryCmd.Flags().String("generator", "", "How the value evolves over time: constant, ramp, sine, random-walk, step or spike, overrides the dictionary generator attribute")
+	metricsPushDictionaryCmd.Flags().String("generator-params", "", "Generator parameters e.g '--generator-params=amplitude=10,period=60', overrides the dictionary generatorParams attribute")
+	metricsPushDictionaryCmd.Flags().String("unit", "", "Unit of the metric e.g seconds or bytes, overrides the dictionary unit attribute")
+	metricsPushDictionaryCmd.Flags().Bool("exemplars", false, "Attach exemplars with random trace IDs to counter and histogram samples, exposed in the OpenMetrics format")
+	metricsPushDictionaryCmd.Flags().Bool("exponential", false, "Add exponential buckets to histograms, exposed as a native histogram and exported as an OTLP exponential histogram")
+	addLabelSetFlags(metricsPushDictionaryCmd)
+	addCardinalityFlags(metricsPushDictionaryCmd)
+	addFaultFlags(metricsPushDictionaryCmd)
+	addLifecycleFlags(metricsPushDictionaryCmd)
+	addDeliveryFlags(metricsPushDictionaryCmd)
+	addMonitorKindFlag(metricsPushDictionaryCmd)
+	addScrapeFlags(metricsPushDictionaryCmd)
 	metricsPushDictionaryCmd.Flags().String("tag-value", "", "If the dictionary contains tags to set, provide value using this flag")
 	metricsPushDictionaryCmd.Flags().Bool("psa-enabled", false, "if the cluster has some Pod Security Admission enabled")
 	metricsPushDictionaryCmd.Flags().String("service-account", "default", "Name of the ServiceAccount to use")
 
 }
 
+// dictionaryMetricLabels returns the labels of a dictionary metric, its tags
+// filled with the comma separated values of --tag-value
+func dictionaryMetricLabels(metric *sources.Metric, metricTagValue string) map[string]string {
+	tagValuesArray := strings.Split(metricTagValue, ",")
+	tags := strings.Split(metric.Tags, "=")
+	valuesMap := make(map[string]string)
+	for i, tag := range tags {
+		if i < len(tagValuesArray) {
+			valuesMap[tag] = tagValuesArray[i]
+		} else {
+			valuesMap[tag] = ""
+		}
+	}
+	return metric.GenerateMetricLabels(valuesMap)
+}
+
+// findDictionaryMetric returns the dictionary metric of the given name
+func findDictionaryMetric(dictionary *sources.Dictionary, name string) (*sources.Metric, error) {
+	for _, metric := range dictionary.Metrics {
+		if metric.Name == name {
+			return &metric, nil
+		}
+	}
+	return nil, fmt.Errorf("metric %s not found in dictionary", name)
+}
+
 // metricsPushDictionaryCmd
 var metricsPushDictionaryCmd = &cobra.Command{
 	Use:     "push-from",
@@ -29,19 +71,19 @@ var metricsPushDictionaryCmd = &cobra.Command{
 
 	Run: func(cmd *cobra.Command, args []string) {
 		metricName, _ := cmd.Flags().GetString("metric")
-		metricValue, _ := cmd.Flags().GetInt("value")
+		metricValue, _ := cmd.Flags().GetFloat64("value")
+		metricType, _ := cmd.Flags().GetString("type")
+		buckets, _ := cmd.Flags().GetFloat64Slice("buckets")
+		quantiles, _ := cmd.Flags().GetFloat64Slice("quantiles")
+		unit, _ := cmd.Flags().GetString("unit")
+		exemplars, _ := cmd.Flags().GetBool("exemplars")
+		exponential, _ := cmd.Flags().GetBool("exponential")
 		metricTagValue, _ := cmd.Flags().GetString("tag-value")
 		isPsaEnabled, _ := cmd.Flags().GetBool("psa-enabled")
 		registry, _ := cmd.Flags().GetString("registry-path")
 		registryPullSecret, _ := cmd.Flags().GetString("image-pull-secret")
 		serviceAccount, _ := cmd.Flags().GetString("service-account")
 
-		knImpl, err := kubernetes.NewClientset(registry, registryPullSecret, serviceAccount)
-		if err != nil {
-			println(err.Error())
-			return
-		}
-
 		// Read metrics from the XML file if provided
 		if metricName != "" {
 
@@ -70,89 +112,124 @@ var metricsPushDictionaryCmd = &cobra.Command{
 			namespace = strings.Split(selectedMetric.Name, ".")[0]
 			applicationName = namespace
 
-			// check if namespace exists
-			isNamespaceExisting, err := knImpl.IsNamespaceExisting(namespace)
+			if metricType == "" {
+				metricType = selectedMetric.PrometheusType()
+			}
+			if unit == "" {
+				unit = selectedMetric.Unit
+			}
+
+			if !cmd.Flags().Changed("generator") {
+				cmd.Flags().Set("generator", selectedMetric.Generator)
+			}
+			if !cmd.Flags().Changed("generator-params") {
+				cmd.Flags().Set("generator-params", selectedMetric.GeneratorParams)
+			}
+			valueGenerator, err := parseGeneratorFlags(cmd)
 			if err != nil {
 				println(err.Error())
 				return
 			}
-			// create namespace
-			if !isNamespaceExisting {
-				knImpl.CreateNamespace(namespace)
+			labelSets, labelValues, err := parseLabelSetFlags(cmd)
+			if err != nil {
+				println(err.Error())
+				return
 			}
-
-			// Check if pod exists by fetching it based on labels
-			services, err := knImpl.FetchServiceByLabels(namespace, Labels{"obs-pusher": "metrics", "element": applicationName})
+			faults, err := parseFaultFlags(cmd)
 			if err != nil {
 				println(err.Error())
 				return
 			}
 
-			// delete existing pod if it exists
-			if len(services.Items) > 0 {
-				for _, pod := range services.Items {
-					knImpl.DeleteService(namespace, pod.Name)
-				}
+			// Resets and absent windows from the dictionary unless overridden
+			resetAt, _ := cmd.Flags().GetFloat64Slice("reset-at")
+			resetEvery := selectedMetric.ResetEvery
+			if cmd.Flags().Changed("reset-every") {
+				resetEvery, _ = cmd.Flags().GetFloat64("reset-every")
 			}
-
-			// Create service with specific name and namespace
-			knImpl.CreateService(namespace, applicationName, Labels{"obs-pusher": "metrics", "element": applicationName})
-
-			// Check if pod exists by fetching it based on labels
-			servicemonitors, err := knImpl.FetchServiceMonitorByLabels(namespace, Labels{"obs-pusher": "metrics", "element": applicationName})
+			rawAbsent := selectedMetric.AbsentWindows()
+			if cmd.Flags().Changed("absent") {
+				rawAbsent, _ = cmd.Flags().GetStringArray("absent")
+			}
+			absent, err := parseWindows(rawAbsent)
 			if err != nil {
 				println(err.Error())
 				return
 			}
 
-			// delete existing pod if it exists
-			if len(servicemonitors.Items) > 0 {
-				for _, pod := range servicemonitors.Items {
-					knImpl.DeleteServiceMonitor(namespace, pod.Name)
+			// Generate the exporter configuration and create pod metric generator
+			config := exporter.Config{Series: []exporter.Series{{
+				Name:        selectedMetric.FullyQualifiedName,
+				Help:        selectedMetric.Description,
+				Type:        metricType,
+				Labels:      dictionaryMetricLabels(selectedMetric, metricTagValue),
+				LabelSets:   labelSets,
+				LabelValues: labelValues,
+				Value:       exporter.Float(metricValue),
+				Buckets:     buckets,
+				Quantiles:   quantiles,
+				Unit:        unit,
+				Generator:   valueGenerator,
+				Cardinality: parseCardinalityFlags(cmd),
+				ResetAt:     resetAt,
+				ResetEvery:  resetEvery,
+				Absent:      absent,
+				Exemplars:   exemplars,
+				Exponential: exponential,
+			}}, Faults: faults}
+
+			// Metrics sent from the CLI do not need the cluster
+			mode, _ := cmd.Flags().GetString("mode")
+			if mode != modeScrape {
+				if err := pushToSink(cmd, mode, config, dictionarySinkDefaults(selectedMetric, metricTagValue)); err != nil {
+					println(err.Error())
 				}
+				return
 			}
 
-			knImpl.CreateServiceMonitor(namespace, applicationName, Labels{"obs-pusher": "metrics", "element": applicationName})
-
-			// Use existing serviceMonitor
-
-			// Check if pod exists by fetching it based on labels
-			podList, err := knImpl.FetchPodByLabels(namespace, Labels{"obs-pusher": "metrics", "element": applicationName})
+			exporterArgs, err := serveMetricsArgs(config)
 			if err != nil {
 				println(err.Error())
 				return
 			}
-
-			// delete existing pod if it exists
-			if len(podList.Items) > 0 {
-				for _, pod := range podList.Items {
-					knImpl.DeletePod(namespace, pod.Name)
-					knImpl.WaitForPodDeletion(namespace, pod.Name)
-				}
+			monitorKind, err := parseMonitorKindFlag(cmd)
+			if err != nil {
+				println(err.Error())
+				return
+			}
+			scrapeSettings, err := parseScrapeFlags(cmd)
+			if err != nil {
+				println(err.Error())
+				return
 			}
 
-			// Prepare tag values
-			tagValuesArray := strings.Split(metricTagValue, ",")
-			tags := strings.Split(selectedMetric.Tags, "=")
-			valuesMap := make(map[string]string)
-			for i, tag := range tags {
-				if i < len(tagValuesArray) {
-					valuesMap[tag] = tagValuesArray[i]
-				} else {
-					valuesMap[tag] = ""
-				}
+			knImpl, err := kubernetes.NewClientset(registry, registryPullSecret, serviceAccount)
+			if err != nil {
+				println(err.Error())
+				return
 			}
 
-			metricTemplate := selectedMetric.GenerateMetricTemplate(valuesMap, metricValue)
-			// Generate metric template and create pod metric generator
-			metricCommand := fmt.Sprintf(`while true; do
-                echo "# HELP %s %s" > /usr/share/nginx/html/metrics;
-                echo "# TYPE %s %s" >> /usr/share/nginx/html/metrics;
-                %s
-                sleep 5;
-                done`, selectedMetric.FullyQualifiedName, selectedMetric.Description, selectedMetric.FullyQualifiedName, selectedMetric.Type, metricTemplate)
+			// check if namespace exists
+			isNamespaceExisting, err := knImpl.IsNamespaceExisting(namespace)
+			if err != nil {
+				println(err.Error())
+				return
+			}
+			// create namespace
+			if !isNamespaceExisting {
+				knImpl.CreateNamespace(namespace)
+			}
 
-			knImpl.CreateMetricPod(namespace, applicationName, []string{"/bin/sh", "-c", metricCommand}, podLabels, isPsaEnabled)
+			// The pod carries the selector of the service and monitors
+			selector := Labels{"obs-pusher": "metrics", "element": applicationName}
+			metricPodLabels := Labels{}
+			metricPodLabels.Append(podLabels)
+			metricPodLabels.Append(selector)
+			err = replaceMetricExporter(knImpl, namespace, applicationName, selector, metricPodLabels, exporterArgs, isPsaEnabled, monitorKind, scrapeSettings)
+			if err != nil {
+				println(err.Error())
+				return
+			}
 			return
 		}
 
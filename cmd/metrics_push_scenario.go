@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/exporter"
+	"github.com/Patrick-Ivann/observability-pusher/internal/generator"
+	"github.com/Patrick-Ivann/observability-pusher/internal/kubernetes"
+	"github.com/Patrick-Ivann/observability-pusher/internal/sources"
+	"github.com/spf13/cobra"
+)
+
+var metricScenarioFilePath string
+
+func init() {
+	addScenarioFlags(metricsPushScenarioCmd)
+}
+
+// addScenarioFlags registers the flags of the commands pushing a metric scenario
+func addScenarioFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&metricScenarioFilePath, "scenario-file", os.Getenv("HOME")+"/.obs-pusher/"+"metrics-scenario.yaml", "Path to the YAML or JSON file describing the metrics to push")
+	cmd.Flags().StringVar(&metricFilePath, "path", os.Getenv("HOME")+"/.obs-pusher/"+"metrics.xml", "path of the source xml, used by scenario metrics referencing the dictionary")
+	cmd.Flags().String("namespace", "default", "Namespace to create resources in when a group does not set one")
+	cmd.Flags().Bool("psa-enabled", false, "if the cluster has some Pod Security Admission enabled")
+	addMonitorKindFlag(cmd)
+	addScrapeFlags(cmd)
+
+	cmd.Flags().String("registry-path", "", "Registry path for the image")
+	cmd.Flags().String("image-pull-secret", "", "Name of the image pull secret")
+	cmd.Flags().String("service-account", "default", "Name of the ServiceAccount to use")
+}
+
+// scenarioExporterConfig returns the exporter configuration of a scenario group,
+// completing the metrics referencing the dictionary with the dictionary definition
+func scenarioExporterConfig(scenario *sources.MetricScenario, group sources.MetricGroup, dictionary *sources.Dictionary) (exporter.Config, error) {
+	config := exporter.Config{Interval: scenario.Interval}
+	if len(group.Faults) > 0 {
+		config.Faults = &exporter.Faults{}
+		if err := decodeStrict(group.Faults, config.Faults); err != nil {
+			return config, fmt.Errorf("invalid faults: %w", err)
+		}
+	}
+	for _, metric := range group.Metrics {
+		var series exporter.Series
+		if err := decodeStrict(metric.Series, &series); err != nil {
+			return config, fmt.Errorf("invalid metric: %w", err)
+		}
+		if metric.Dictionary != "" {
+			if dictionary == nil {
+				return config, fmt.Errorf("metric %s references the dictionary but none was loaded", metric.Dictionary)
+			}
+			if err := completeFromDictionary(&series, metric.Dictionary, dictionary); err != nil {
+				return config, err
+			}
+		}
+		config.Series = append(config.Series, series)
+	}
+	return config, nil
+}
+
+// completeFromDictionary sets the unset fields of the series which the named
+// dictionary metric defines
+func completeFromDictionary(series *exporter.Series, name string, dictionary *sources.Dictionary) error {
+	definition, err := findDictionaryMetric(dictionary, name)
+	if err != nil {
+		return err
+	}
+
+	if series.Name == "" {
+		series.Name = definition.FullyQualifiedName
+	}
+	if series.Help == "" {
+		series.Help = definition.Description
+	}
+	if series.Type == "" {
+		series.Type = definition.PrometheusType()
+	}
+	if series.Unit == "" {
+		series.Unit = definition.Unit
+	}
+	if series.Generator == nil && definition.Generator != "" {
+		spec, err := generator.ParseSpec(definition.Generator, definition.GeneratorParams)
+		if err != nil {
+			return fmt.Errorf("invalid generator for dictionary metric %s: %w", definition.Name, err)
+		}
+		series.Generator = &spec
+	}
+	if series.ResetEvery == 0 {
+		series.ResetEvery = definition.ResetEvery
+	}
+	if len(series.Absent) == 0 {
+		absent, err := parseWindows(definition.AbsentWindows())
+		if err != nil {
+			return fmt.Errorf("invalid absent attribute on metric %s: %w", definition.Name, err)
+		}
+		series.Absent = absent
+	}
+	return nil
+}
+
+// decodeStrict decodes JSON, failing on unknown fields so misspelled settings
+// are not silently ignored
+func decodeStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// replaceMetricExporter deletes the service, service monitor, pod monitor and pod
+// matching the selector then recreates the pod, serving metrics with the given
+// exporter arguments, along with the resources of the monitor kind
+func replaceMetricExporter(knImpl *kubernetes.Client, namespace, name string, selector Labels, podLabels Labels, exporterArgs []string, isPsaEnabled bool, monitorKind string, scrapeSettings kubernetes.ScrapeSettings) error {
+	services, err := knImpl.FetchServiceByLabels(namespace, selector)
+	if err != nil {
+		return err
+	}
+	for _, service := range services.Items {
+		knImpl.DeleteService(namespace, service.Name)
+	}
+
+	// Monitors of another kind left by a previous push are removed as well
+	servicemonitors, err := knImpl.FetchServiceMonitorByLabels(namespace, selector)
+	if err != nil {
+		return err
+	}
+	for _, serviceMonitor := range servicemonitors.Items {
+		knImpl.DeleteServiceMonitor(namespace, serviceMonitor.Name)
+	}
+	podmonitors, err := knImpl.FetchPodMonitorByLabels(namespace, selector)
+	if err != nil {
+		return err
+	}
+	for _, podMonitor := range podmonitors.Items {
+		knImpl.DeletePodMonitor(namespace, podMonitor.Name)
+	}
+
+	var annotations map[string]string
+	switch monitorKind {
+	case monitorServiceMonitor:
+		if err := knImpl.CreateService(namespace, name, selector); err != nil {
+			return err
+		}
+		if err := knImpl.CreateServiceMonitor(namespace, name, selector, scrapeSettings); err != nil {
+			return err
+		}
+	case monitorPodMonitor:
+		if err := knImpl.CreatePodMonitor(namespace, name, selector, scrapeSettings); err != nil {
+			return err
+		}
+	case monitorAnnotations:
+		annotations = kubernetes.ScrapeAnnotations()
+	}
+
+	podList, err := knImpl.FetchPodByLabels(namespace, selector)
+	if err != nil {
+		return err
+	}
+	for _, pod := range podList.Items {
+		knImpl.DeletePod(namespace, pod.Name)
+		knImpl.WaitForPodDeletion(namespace, pod.Name)
+	}
+
+	return knImpl.CreateMetricPod(namespace, name, exporterArgs, podLabels, annotations, isPsaEnabled)
+}
+
+// metricsPushScenarioCmd pushes every metric of a scenario file, one exporter pod per group
+var metricsPushScenarioCmd = &cobra.Command{
+	Use:     "push-scenario",
+	Short:   "Push the metrics described in a scenario file",
+	Long:    "Push the metrics described in a scenario file, each group of the scenario is served by its own exporter pod",
+	Example: "--scenario-file=./scenario.yaml --namespace=<> --psa-enabled",
+	Run: func(cmd *cobra.Command, args []string) {
+		scenario, err := sources.ParseScenario(metricScenarioFilePath)
+		if err != nil {
+			println(err.Error())
+			return
+		}
+		if _, err := pushMetricScenario(cmd, scenario); err != nil {
+			println(err.Error())
+			return
+		}
+	},
+}
+
+// pushMetricScenario validates every group of the scenario then replaces the
+// exporter of each of them, returning the client used
+func pushMetricScenario(cmd *cobra.Command, scenario *sources.MetricScenario) (*kubernetes.Client, error) {
+	defaultNamespace, _ := cmd.Flags().GetString("namespace")
+	isPsaEnabled, _ := cmd.Flags().GetBool("psa-enabled")
+	registry, _ := cmd.Flags().GetString("registry-path")
+	registryPullSecret, _ := cmd.Flags().GetString("image-pull-secret")
+	serviceAccount, _ := cmd.Flags().GetString("service-account")
+
+	monitorKind, err := parseMonitorKindFlag(cmd)
+	if err != nil {
+		return nil, err
+	}
+	defaultScrapeSettings, err := parseScrapeFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	var dictionary *sources.Dictionary
+	if scenario.UsesDictionary() {
+		dictionary, err = sources.ReadDictionary(metricFilePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Build and validate every exporter before touching the cluster
+	exporterArgsByGroup := make(map[string][]string)
+	scrapeSettingsByGroup := make(map[string]kubernetes.ScrapeSettings)
+	for _, group := range scenario.Groups {
+		config, err := scenarioExporterConfig(scenario, group, dictionary)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", group.Name, err)
+		}
+		exporterArgs, err := serveMetricsArgs(config)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", group.Name, err)
+		}
+		exporterArgsByGroup[group.Name] = exporterArgs
+
+		// The scrape settings of a group replace the ones of the command line
+		scrapeSettingsByGroup[group.Name] = defaultScrapeSettings
+		if group.Scrape != nil {
+			settings, err := scrapeSettings(*group.Scrape)
+			if err != nil {
+				return nil, fmt.Errorf("group %s: %w", group.Name, err)
+			}
+			scrapeSettingsByGroup[group.Name] = settings
+		}
+	}
+
+	knImpl, err := kubernetes.NewClientset(registry, registryPullSecret, serviceAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range scenario.Groups {
+		namespace := group.Namespace
+		if namespace == "" {
+			namespace = defaultNamespace
+		}
+
+		// check if namespace exists
+		isNamespaceExisting, err := knImpl.IsNamespaceExisting(namespace)
+		if err != nil {
+			return nil, err
+		}
+		// create namespace
+		if !isNamespaceExisting {
+			knImpl.CreateNamespace(namespace)
+		}
+
+		// Only the resources of this group are replaced
+		selector := Labels{"obs-pusher": "metrics", "element": group.Name}
+		groupPodLabels := Labels{}
+		groupPodLabels.Append(parseLabels(group.Labels))
+		groupPodLabels.Append(selector)
+
+		err = replaceMetricExporter(knImpl, namespace, group.Name, selector, groupPodLabels, exporterArgsByGroup[group.Name], isPsaEnabled, monitorKind, scrapeSettingsByGroup[group.Name])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("group %s pushed to namespace %s\n", group.Name, namespace)
+	}
+	return knImpl, nil
+}
@@ -23,10 +23,10 @@ var metricsListCmd = &cobra.Command{
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 1, 1, ' ', tabwriter.TabIndent)
-		fmt.Fprintln(w, "ID\tDESCRIPTION\tTAGS\tTYPE")
+		fmt.Fprintln(w, "ID\tDESCRIPTION\tTAGS\tTYPE\tPROMETHEUS TYPE")
 		for _, metric := range dictionary.Metrics {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
-				metric.Name, metric.Description, metric.Tags, metric.Type)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				metric.Name, metric.Description, metric.Tags, metric.Type, metric.PrometheusType())
 		}
 		w.Flush()
 
@@ -45,4 +45,7 @@ func init() {
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	rootCmd.AddCommand(metricsCmd)
 	rootCmd.AddCommand(eventsCmd)
+	rootCmd.AddCommand(serveMetricsCmd)
+	rootCmd.AddCommand(emitLogsCmd)
+	rootCmd.AddCommand(verifyCmd)
 }
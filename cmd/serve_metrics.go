@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/exporter"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	serveMetricsCmd.Flags().String("listen-address", exporter.DefaultListenAddress, "Address to serve /metrics on")
+	serveMetricsCmd.Flags().String("config", "", "Exporter configuration as inline JSON")
+	serveMetricsCmd.Flags().String("config-file", "", "Path to a JSON file containing the exporter configuration")
+}
+
+// serveMetricsArgs returns the container arguments running serve-metrics with the given configuration
+func serveMetricsArgs(config exporter.Config) ([]string, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	encodedConfig, err := config.Encode()
+	if err != nil {
+		return nil, err
+	}
+	return []string{"serve-metrics", "--config", encodedConfig}, nil
+}
+
+// serveMetricsCmd serves metrics over HTTP, it is the entrypoint of the metric pods
+var serveMetricsCmd = &cobra.Command{
+	Use:     "serve-metrics",
+	Short:   "Serve metrics over HTTP",
+	Long:    "Serve the configured metrics on /metrics using the Prometheus client library, this is what runs inside the metric pods",
+	Example: `serve-metrics --config='{"series":[{"name":"test_metric","value":4}]}'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listenAddress, _ := cmd.Flags().GetString("listen-address")
+		inlineConfig, _ := cmd.Flags().GetString("config")
+		configFile, _ := cmd.Flags().GetString("config-file")
+
+		var config *exporter.Config
+		var err error
+		if configFile != "" {
+			config, err = exporter.ReadConfig(configFile)
+		} else {
+			config, err = exporter.ParseConfig([]byte(inlineConfig))
+		}
+		if err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		err = exporter.Serve(ctx, listenAddress, *config)
+		stop()
+		if err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+	},
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/verify"
+	"github.com/spf13/cobra"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check pushed data reached the backends",
+}
+
+func init() {
+	verifyCmd.AddCommand(verifyMetricsCmd)
+	verifyCmd.AddCommand(verifyEventsCmd)
+}
+
+// addVerifyFlags registers the flags shared by the verify commands
+func addVerifyFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64("timeout", 300, "Seconds to wait for every check to pass before failing")
+	cmd.Flags().Float64("retry-interval", 15, "Interval in seconds between two attempts of the failing checks")
+	cmd.Flags().StringArray("header", nil, "HTTP header added to the queries as Name=Value, repeatable")
+	cmd.Flags().String("report-format", verify.FormatText, "Format of the report: text, json or junit")
+	cmd.Flags().String("report-file", "", "File the report is written to, the standard output when empty")
+}
+
+// runChecks runs the checks until they pass or the timeout, writes the report
+// and exits with 1 when a check failed
+func runChecks(cmd *cobra.Command, suite string, checks []verify.Check) {
+	timeout, _ := cmd.Flags().GetFloat64("timeout")
+	retryInterval, _ := cmd.Flags().GetFloat64("retry-interval")
+	reportFormat, _ := cmd.Flags().GetString("report-format")
+	reportFile, _ := cmd.Flags().GetString("report-file")
+
+	if retryInterval <= 0 {
+		println("--retry-interval must be positive")
+		os.Exit(1)
+	}
+	// Catch an unknown format before waiting for the checks
+	if err := verify.WriteReport(io.Discard, reportFormat, suite, nil); err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "running %d checks for up to %vs\n", len(checks), timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), seconds(timeout))
+	results := verify.Run(ctx, checks, seconds(retryInterval))
+	cancel()
+
+	output := os.Stdout
+	if reportFile != "" {
+		file, err := os.Create(reportFile)
+		if err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+		output = file
+	}
+	err := verify.WriteReport(output, reportFormat, suite, results)
+	if output != os.Stdout {
+		output.Close()
+	}
+	if err != nil {
+		println(err.Error())
+		os.Exit(1)
+	}
+	if !verify.Passed(results) {
+		os.Exit(1)
+	}
+}
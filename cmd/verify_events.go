@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/emitter"
+	"github.com/Patrick-Ivann/observability-pusher/internal/sources"
+	"github.com/Patrick-Ivann/observability-pusher/internal/verify"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	verifyEventsCmd.Flags().String("loki-url", "", "Loki address searched for the events e.g 'http://loki:3100'")
+	verifyEventsCmd.Flags().String("selector", `{namespace="default"}`, "LogQL stream selector of the pushed events in Loki")
+	verifyEventsCmd.Flags().String("elasticsearch-url", "", "Elasticsearch or OpenSearch address searched for the events instead of Loki e.g 'http://elasticsearch:9200'")
+	verifyEventsCmd.Flags().String("index", "", "Elasticsearch index or pattern searched e.g 'logs-*', every index when empty")
+	verifyEventsCmd.Flags().String("message-field", "message", "Elasticsearch field holding the log line")
+	verifyEventsCmd.Flags().String("timestamp-field", "@timestamp", "Elasticsearch field holding the time of the log line")
+	verifyEventsCmd.Flags().Float64("since", 3600, "Seconds before now from which the events are searched")
+	verifyEventsCmd.Flags().String("event-file", os.Getenv("HOME")+"/.obs-pusher/"+"events.xml", "Path to the XML file for the event")
+	verifyEventsCmd.Flags().String("event-id", "", "The ID of the pushed dictionary event to check")
+	verifyEventsCmd.Flags().String("message", "", "Values of the pushed dictionary event template e.g '--message=value1,value2', or the pushed message when no event-id is given")
+	verifyEventsCmd.Flags().String("event-sequence-file", "", "Path to the pushed json sequence of events to check")
+	verifyEventsCmd.Flags().String("format", emitter.FormatJSON, "Format the dictionary events were pushed in: "+strings.Join(emitter.Formats, ", "))
+	addVerifyFlags(verifyEventsCmd)
+}
+
+// dictionaryEventExpectation returns the expectation of a dictionary event pushed
+// in the format, whose text may be escaped in the log line
+func dictionaryEventExpectation(event sources.Log, values []string, count int, format emitter.Format) verify.EventExpectation {
+	text := event.RenderText(values)
+	return verify.EventExpectation{ID: event.ID, Text: text, Match: format.TextMatch(text), MinCount: count}
+}
+
+// eventsToVerify returns the events of the sequence file, the dictionary event or the message given by the flags
+func eventsToVerify(cmd *cobra.Command) ([]verify.EventExpectation, error) {
+	dictionaryPath, _ := cmd.Flags().GetString("event-file")
+	eventID, _ := cmd.Flags().GetString("event-id")
+	message, _ := cmd.Flags().GetString("message")
+	sequenceFilePath, _ := cmd.Flags().GetString("event-sequence-file")
+	kind, _ := cmd.Flags().GetString("format")
+	format := emitter.Format{Kind: kind}
+	if err := format.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case sequenceFilePath != "" && (eventID != "" || message != ""):
+		return nil, fmt.Errorf("--event-sequence-file cannot be used with --event-id or --message")
+	case sequenceFilePath != "":
+		dictionary, err := sources.ReadDictionary(dictionaryPath)
+		if err != nil {
+			return nil, err
+		}
+		sequenceEvents, err := sources.ParseSequence(sequenceFilePath)
+		if err != nil {
+			return nil, err
+		}
+		// Repetitions of the same event are counted together
+		var expectations []verify.EventExpectation
+		indexes := make(map[[2]string]int)
+		for _, seqEvent := range sequenceEvents {
+			for _, event := range dictionary.Logs {
+				if event.ID != seqEvent.ID || seqEvent.Repetition <= 0 {
+					continue
+				}
+				expectation := dictionaryEventExpectation(event, seqEvent.Values, seqEvent.Repetition, format)
+				key := [2]string{expectation.ID, expectation.Text}
+				if index, found := indexes[key]; found {
+					expectations[index].MinCount += seqEvent.Repetition
+					continue
+				}
+				indexes[key] = len(expectations)
+				expectations = append(expectations, expectation)
+			}
+		}
+		if len(expectations) == 0 {
+			return nil, fmt.Errorf("no event of the sequence is in the dictionary")
+		}
+		return expectations, nil
+	case eventID != "":
+		dictionary, err := sources.ReadDictionary(dictionaryPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range dictionary.Logs {
+			if event.ID == eventID {
+				return []verify.EventExpectation{dictionaryEventExpectation(event, strings.Split(message, ","), 1, format)}, nil
+			}
+		}
+		return nil, fmt.Errorf("notification with ID %s not found", eventID)
+	case message != "":
+		return []verify.EventExpectation{{Text: message}}, nil
+	default:
+		return nil, fmt.Errorf("--event-sequence-file, --event-id or --message is required to know the events to check")
+	}
+}
+
+// newEventSource builds the log backend searched for the events from the flags
+func newEventSource(cmd *cobra.Command) (verify.EventSource, error) {
+	lokiURL, _ := cmd.Flags().GetString("loki-url")
+	selector, _ := cmd.Flags().GetString("selector")
+	elasticsearchURL, _ := cmd.Flags().GetString("elasticsearch-url")
+	index, _ := cmd.Flags().GetString("index")
+	messageField, _ := cmd.Flags().GetString("message-field")
+	timestampField, _ := cmd.Flags().GetString("timestamp-field")
+	rawHeaders, _ := cmd.Flags().GetStringArray("header")
+	headers, err := parseHeaders(rawHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case lokiURL != "" && elasticsearchURL != "":
+		return nil, fmt.Errorf("--loki-url and --elasticsearch-url cannot be used together")
+	case lokiURL != "":
+		return &verify.Loki{URL: lokiURL, Selector: selector, Headers: headers}, nil
+	case elasticsearchURL != "":
+		return &verify.Elasticsearch{URL: elasticsearchURL, Index: index, MessageField: messageField, TimestampField: timestampField, Headers: headers}, nil
+	default:
+		return nil, fmt.Errorf("--loki-url or --elasticsearch-url is required to search the events")
+	}
+}
+
+// verifyEventsCmd checks pushed events are searchable in the log backend
+var verifyEventsCmd = &cobra.Command{
+	Use:     "events",
+	Short:   "Check pushed events reached the log backend",
+	Long:    "Search Loki or Elasticsearch for every pushed event until all of them are found, reporting their labels and how late the ones missing at an earlier attempt arrived in a text, JSON or JUnit report and exiting with 1 when an event is still missing at the timeout",
+	Example: "--loki-url=http://loki:3100 --selector='{namespace=\"default\"}' --event-sequence-file=./sequence.json --report-format=junit --report-file=report.xml",
+	Run: func(cmd *cobra.Command, args []string) {
+		since, _ := cmd.Flags().GetFloat64("since")
+
+		source, err := newEventSource(cmd)
+		if err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+		expectations, err := eventsToVerify(cmd)
+		if err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+
+		start := time.Now().Add(-seconds(since))
+		var checks []verify.Check
+		for _, expectation := range expectations {
+			checks = append(checks, &verify.EventCheck{Source: source, Expectation: expectation, Since: start})
+		}
+		runChecks(cmd, "verify events", checks)
+	},
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/exporter"
+	"github.com/Patrick-Ivann/observability-pusher/internal/sources"
+	"github.com/Patrick-Ivann/observability-pusher/internal/verify"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	verifyMetricsCmd.Flags().String("prometheus-url", "", "Prometheus compatible address queried for the series e.g 'http://prometheus:9090'")
+	verifyMetricsCmd.Flags().String("scenario-file", "", "Path to the scenario file whose series are checked, instead of a single metric")
+	verifyMetricsCmd.Flags().String("path", os.Getenv("HOME")+"/.obs-pusher/"+"metrics.xml", "path of the source xml, used by scenario metrics referencing the dictionary")
+	verifyMetricsCmd.Flags().String("metric", "", "Name of the pushed metric to check")
+	verifyMetricsCmd.Flags().Float64("value", 0, "Value of the pushed metric, only compared for constant gauges and untyped metrics")
+	verifyMetricsCmd.Flags().String("type", "gauge", "Type of the pushed metric: gauge, counter, histogram, summary or untyped")
+	verifyMetricsCmd.Flags().String("unit", "", "Unit of the pushed metric, appended to the name when missing")
+	verifyMetricsCmd.Flags().String("labels", "", `Labels of the pushed metric as "key:value,anotherkey:anothervalue"`)
+	verifyMetricsCmd.Flags().Float64("tolerance", 0, "Largest accepted difference between the queried and the pushed value")
+	addVerifyFlags(verifyMetricsCmd)
+}
+
+// metricSeriesToVerify returns the series of the scenario file, or the one described by the metric flags
+func metricSeriesToVerify(cmd *cobra.Command) ([]exporter.Series, error) {
+	scenarioFilePath, _ := cmd.Flags().GetString("scenario-file")
+	dictionaryPath, _ := cmd.Flags().GetString("path")
+	metricName, _ := cmd.Flags().GetString("metric")
+	metricValue, _ := cmd.Flags().GetFloat64("value")
+	metricType, _ := cmd.Flags().GetString("type")
+	unit, _ := cmd.Flags().GetString("unit")
+	rawLabels, _ := cmd.Flags().GetString("labels")
+
+	switch {
+	case scenarioFilePath != "" && metricName != "":
+		return nil, fmt.Errorf("--scenario-file and --metric cannot be used together")
+	case metricName != "":
+		labels := Labels{}
+		if rawLabels != "" {
+			if err := labels.Set(rawLabels); err != nil {
+				return nil, err
+			}
+		}
+		return []exporter.Series{{Name: metricName, Type: metricType, Unit: unit, Value: exporter.Float(metricValue), Labels: labels}}, nil
+	case scenarioFilePath != "":
+		scenario, err := sources.ParseScenario(scenarioFilePath)
+		if err != nil {
+			return nil, err
+		}
+		var dictionary *sources.Dictionary
+		if scenario.UsesDictionary() {
+			dictionary, err = sources.ReadDictionary(dictionaryPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+		var series []exporter.Series
+		for _, group := range scenario.Groups {
+			config, err := scenarioExporterConfig(scenario, group, dictionary)
+			if err != nil {
+				return nil, fmt.Errorf("group %s: %w", group.Name, err)
+			}
+			series = append(series, config.Series...)
+		}
+		return series, nil
+	default:
+		return nil, fmt.Errorf("--scenario-file or --metric is required to know the series to check")
+	}
+}
+
+// verifyMetricsCmd checks pushed series are queryable in Prometheus
+var verifyMetricsCmd = &cobra.Command{
+	Use:     "metrics",
+	Short:   "Check pushed metrics reached Prometheus",
+	Long:    "Query a Prometheus compatible /api/v1/query endpoint for every pushed series until all of them are found with the pushed labels and value, writing a text, JSON or JUnit report and exiting with 1 when a series is still missing at the timeout",
+	Example: "--prometheus-url=http://prometheus:9090 --scenario-file=./scenario.yaml --report-format=junit --report-file=report.xml",
+	Run: func(cmd *cobra.Command, args []string) {
+		prometheusURL, _ := cmd.Flags().GetString("prometheus-url")
+		tolerance, _ := cmd.Flags().GetFloat64("tolerance")
+		rawHeaders, _ := cmd.Flags().GetStringArray("header")
+
+		if prometheusURL == "" {
+			println("--prometheus-url is required")
+			os.Exit(1)
+		}
+		headers, err := parseHeaders(rawHeaders)
+		if err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+		series, err := metricSeriesToVerify(cmd)
+		if err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+		expectations, err := verify.MetricExpectations(exporter.Config{Series: series})
+		if err != nil {
+			println(err.Error())
+			os.Exit(1)
+		}
+
+		prometheus := &verify.Prometheus{URL: prometheusURL, Headers: headers}
+		var checks []verify.Check
+		for _, expectation := range expectations {
+			checks = append(checks, &verify.MetricCheck{Prometheus: prometheus, Expectation: expectation, Tolerance: tolerance})
+		}
+		runChecks(cmd, "verify metrics", checks)
+	},
+}
@@ -0,0 +1,154 @@
+// Package alerts checks whether an alert fires, through the Prometheus or the
+// Alertmanager HTTP API
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Source reports whether an alert is firing
+type Source interface {
+	// Firing reports whether an alert with the name and at least the labels is firing
+	Firing(ctx context.Context, alertName string, labels map[string]string) (bool, error)
+}
+
+// Prometheus reads the alerts evaluated by a Prometheus server, pending alerts
+// not being firing yet
+type Prometheus struct {
+	URL string
+	// Headers added to every request, e.g. an Authorization header
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// Firing reports whether an alert with the name and the labels is in the firing state
+func (p *Prometheus) Firing(ctx context.Context, alertName string, labels map[string]string) (bool, error) {
+	var response struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			Alerts []struct {
+				Labels map[string]string `json:"labels"`
+				State  string            `json:"state"`
+			} `json:"alerts"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, p.Client, strings.TrimSuffix(p.URL, "/")+"/api/v1/alerts", p.Headers, &response); err != nil {
+		return false, err
+	}
+	if response.Status != "success" {
+		return false, fmt.Errorf("prometheus alerts query failed: %s", response.Error)
+	}
+	for _, alert := range response.Data.Alerts {
+		if alert.Labels["alertname"] == alertName && hasLabels(alert.Labels, labels) && alert.State == "firing" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Alertmanager reads the alerts received by an Alertmanager, silenced and
+// inhibited alerts counting as firing since Prometheus did send them
+type Alertmanager struct {
+	URL string
+	// Headers added to every request, e.g. an Authorization header
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// Firing reports whether an alert with the name and the labels was received and is active
+func (a *Alertmanager) Firing(ctx context.Context, alertName string, labels map[string]string) (bool, error) {
+	var alerts []struct {
+		Labels map[string]string `json:"labels"`
+	}
+	query := url.Values{"filter": {fmt.Sprintf("alertname=%q", alertName)}}
+	for name, value := range labels {
+		query.Add("filter", fmt.Sprintf("%s=%q", name, value))
+	}
+	if err := getJSON(ctx, a.Client, strings.TrimSuffix(a.URL, "/")+"/api/v2/alerts?"+query.Encode(), a.Headers, &alerts); err != nil {
+		return false, err
+	}
+	for _, alert := range alerts {
+		if alert.Labels["alertname"] == alertName && hasLabels(alert.Labels, labels) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// WaitForFiring polls the source every interval until the alert with the labels
+// fires, and fails with the last polling error if any once the context is done
+func WaitForFiring(ctx context.Context, source Source, alertName string, labels map[string]string, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		firing, err := source.Firing(ctx, alertName, labels)
+		if firing {
+			return nil
+		}
+		if err != nil && ctx.Err() == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("alert %s is not firing: %w", alertName, lastErr)
+			}
+			return fmt.Errorf("alert %s is not firing", alertName)
+		case <-ticker.C:
+		}
+	}
+}
+
+// hasLabels reports whether the alert labels hold every expected label
+func hasLabels(alertLabels, expected map[string]string) bool {
+	for name, value := range expected {
+		if alertLabels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func getJSON(ctx context.Context, client *http.Client, target string, headers map[string]string, value any) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return fmt.Errorf("error creating alerts request: %w", err)
+	}
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("User-Agent", "obs-pusher")
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error fetching alerts: %w", err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("error reading alerts: %w", err)
+	}
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("GET %s failed with status %s: %s", target, response.Status, bytes.TrimSpace(body))
+	}
+	if err := json.Unmarshal(body, value); err != nil {
+		return fmt.Errorf("error decoding alerts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package alerts
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPrometheusFiring(t *testing.T) {
+	var polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/alerts" {
+			http.NotFound(w, r)
+			return
+		}
+		// Pending on the first poll, firing afterwards
+		state := "pending"
+		if polls.Add(1) > 1 {
+			state = "firing"
+		}
+		w.Write([]byte(`{"status":"success","data":{"alerts":[
+			{"labels":{"alertname":"Other"},"state":"firing"},
+			{"labels":{"alertname":"QueueTooLong","obs_pusher_run":"previous"},"state":"firing"},
+			{"labels":{"alertname":"QueueTooLong","obs_pusher_run":"r1","severity":"page"},"state":"` + state + `"}
+		]}}`))
+	}))
+	defer server.Close()
+
+	source := &Prometheus{URL: server.URL}
+	run := map[string]string{"obs_pusher_run": "r1"}
+	firing, err := source.Firing(context.Background(), "QueueTooLong", run)
+	if err != nil || firing {
+		t.Fatalf("expected a pending alert not to be firing, got %v (%v)", firing, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := WaitForFiring(ctx, source, "QueueTooLong", run, 10*time.Millisecond); err != nil {
+		t.Errorf("expected the alert to fire, got %v", err)
+	}
+}
+
+func TestAlertmanagerFiring(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filters := r.URL.Query()["filter"]
+		if r.URL.Path != "/api/v2/alerts" || len(filters) != 2 || filters[0] != `alertname="QueueTooLong"` || filters[1] != `obs_pusher_run="r1"` {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.Write([]byte(`[{"labels":{"alertname":"QueueTooLong","obs_pusher_run":"r1"},"status":{"state":"active"}}]`))
+	}))
+	defer server.Close()
+
+	source := &Alertmanager{URL: server.URL + "/"}
+	for alertName, expected := range map[string]bool{"QueueTooLong": true, "Other": false} {
+		firing, err := source.Firing(context.Background(), alertName, map[string]string{"obs_pusher_run": "r1"})
+		if err != nil || firing != expected {
+			t.Errorf("expected %s firing to be %v, got %v (%v)", alertName, expected, firing, err)
+		}
+	}
+}
+
+func TestWaitForFiringDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := WaitForFiring(ctx, &Prometheus{URL: server.URL}, "QueueTooLong", nil, 10*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("expected the last polling error once the deadline passed, got %v", err)
+	}
+}
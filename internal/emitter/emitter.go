@@ -0,0 +1,227 @@
+// Package emitter writes a rendered sequence of log lines to stdout and stderr,
+// it is what runs inside the log pods
+package emitter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Streams a step can be written to
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// Sequence is a list of messages, each written a number of times with an interval
+type Sequence struct {
+	Steps []Step `json:"steps"`
+	// Loop writes the steps again once the last one is done, until the emitter is stopped
+	Loop bool `json:"loop,omitempty"`
+	// Jitter randomly shifts every interval by up to this fraction of it, e.g. 0.1 for ±10%
+	Jitter float64 `json:"jitter,omitempty"`
+	// Format of the events of the steps
+	Format Format `json:"format"`
+	// Syslog sends the lines to a syslog server instead of stdout and stderr
+	Syslog *Syslog `json:"syslog,omitempty"`
+	// Loki pushes the lines to the Loki push API instead of stdout and stderr
+	Loki *Loki `json:"loki,omitempty"`
+}
+
+// Step is a message, or an event rendered in the sequence format, written
+// Repetition times, waiting Interval seconds after each line but the last one of
+// a sequence which does not loop
+type Step struct {
+	Message    string  `json:"message,omitempty"`
+	Event      *Event  `json:"event,omitempty"`
+	Stream     string  `json:"stream,omitempty"`
+	Repetition int     `json:"repetition"`
+	Interval   float64 `json:"interval,omitempty"`
+}
+
+// ParseSequence decodes a JSON sequence
+func ParseSequence(data []byte) (*Sequence, error) {
+	var sequence Sequence
+	if err := json.Unmarshal(data, &sequence); err != nil {
+		return nil, fmt.Errorf("error unmarshalling log sequence: %w", err)
+	}
+	return &sequence, nil
+}
+
+// ReadSequence reads a JSON sequence from a file
+func ReadSequence(filePath string) (*Sequence, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading log sequence: %w", err)
+	}
+	return ParseSequence(data)
+}
+
+// Encode returns the JSON form of the sequence, as read by emit-logs
+func (s Sequence) Encode() (string, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling log sequence: %w", err)
+	}
+	return string(data), nil
+}
+
+// Validate checks the sequence can be written, keeping the parsed format template
+func (s *Sequence) Validate() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("the log sequence has no step")
+	}
+	if s.Jitter < 0 || s.Jitter > 1 {
+		return fmt.Errorf("jitter must be between 0 and 1, got %v", s.Jitter)
+	}
+	if err := s.Format.Validate(); err != nil {
+		return err
+	}
+	if s.Syslog != nil && s.Loki != nil {
+		return fmt.Errorf("lines are sent either to syslog or to Loki")
+	}
+	if s.Syslog != nil {
+		if err := s.Syslog.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.Loki != nil {
+		if err := s.Loki.Validate(); err != nil {
+			return err
+		}
+	}
+	lines := 0
+	for i, step := range s.Steps {
+		if step.Stream != "" && step.Stream != StreamStdout && step.Stream != StreamStderr {
+			return fmt.Errorf("step %d: unknown stream %q, expected %s or %s", i, step.Stream, StreamStdout, StreamStderr)
+		}
+		if step.Repetition < 0 || step.Interval < 0 {
+			return fmt.Errorf("step %d: repetition and interval cannot be negative", i)
+		}
+		lines += step.Repetition
+	}
+	if lines == 0 {
+		return fmt.Errorf("the log sequence writes no line")
+	}
+	return nil
+}
+
+// output receives the lines of a sequence
+type output interface {
+	// Write sends a line of the step written at the given time
+	Write(ctx context.Context, step Step, line string, now time.Time) error
+	Close() error
+}
+
+// streamOutput writes the lines to stdout or stderr depending on the step stream
+type streamOutput struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (o *streamOutput) Write(ctx context.Context, step Step, line string, now time.Time) error {
+	w := o.stdout
+	if step.Stream == StreamStderr {
+		w = o.stderr
+	}
+	if _, err := io.WriteString(w, line+"\n"); err != nil {
+		return fmt.Errorf("error writing log line: %w", err)
+	}
+	return nil
+}
+
+func (o *streamOutput) Close() error {
+	return nil
+}
+
+// Run writes the sequence until it is done or the context is cancelled, to
+// stdout and stderr or to the output of the sequence. Lines are scheduled from
+// the start of the run, so slow writes do not delay the following ones. It
+// returns the context error when the context is cancelled before a sequence
+// which does not loop is done
+func Run(ctx context.Context, sequence Sequence, stdout, stderr io.Writer) error {
+	if err := sequence.Validate(); err != nil {
+		return err
+	}
+	var out output = &streamOutput{stdout: stdout, stderr: stderr}
+	switch {
+	case sequence.Syslog != nil:
+		var err error
+		out, err = newSyslogOutput(ctx, *sequence.Syslog)
+		if err != nil {
+			return err
+		}
+	case sequence.Loki != nil:
+		var err error
+		out, err = newLokiOutput(*sequence.Loki)
+		if err != nil {
+			return err
+		}
+	}
+	defer out.Close()
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+	// A sequence that does not loop returns right after its last line
+	last := len(sequence.Steps) - 1
+	for last >= 0 && sequence.Steps[last].Repetition == 0 {
+		last--
+	}
+
+	next := time.Now()
+	for {
+		for s, step := range sequence.Steps {
+			for i := 0; i < step.Repetition; i++ {
+				if err := ctx.Err(); err != nil {
+					return stopped(sequence, err)
+				}
+				now := time.Now()
+				line := step.Message
+				if step.Event != nil {
+					var err error
+					line, err = sequence.Format.Render(*step.Event, now)
+					if err != nil {
+						return err
+					}
+				}
+				if err := out.Write(ctx, step, line, now); err != nil {
+					return err
+				}
+				if !sequence.Loop && s == last && i == step.Repetition-1 {
+					return nil
+				}
+
+				interval := step.Interval
+				if sequence.Jitter > 0 {
+					interval *= 1 + sequence.Jitter*(2*random.Float64()-1)
+				}
+				next = next.Add(time.Duration(interval * float64(time.Second)))
+				timer.Reset(time.Until(next))
+				select {
+				case <-ctx.Done():
+					return stopped(sequence, ctx.Err())
+				case <-timer.C:
+				}
+			}
+		}
+		if !sequence.Loop {
+			return nil
+		}
+	}
+}
+
+// stopped returns the error of a run stopped by the context, a looping sequence never completes so it is not an error
+func stopped(sequence Sequence, err error) error {
+	if sequence.Loop {
+		return nil
+	}
+	return fmt.Errorf("stopped before the end of the log sequence: %w", err)
+}
@@ -0,0 +1,399 @@
+package emitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestRun(t *testing.T) {
+	sequence := Sequence{Steps: []Step{
+		{Message: `{"Text":"it's \"$HOME\" and ` + "`date`" + `"}`, Repetition: 2, Interval: 0.02},
+		{Message: "first line\nsecond line", Stream: StreamStderr, Repetition: 1, Interval: 10},
+		{Message: "skipped", Repetition: 0, Interval: 10},
+	}}
+	var stdout, stderr bytes.Buffer
+	start := time.Now()
+	if err := Run(context.Background(), sequence, &stdout, &stderr); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	elapsed := time.Since(start)
+
+	expectedStdout := `{"Text":"it's \"$HOME\" and ` + "`date`" + `"}` + "\n" + `{"Text":"it's \"$HOME\" and ` + "`date`" + `"}` + "\n"
+	if stdout.String() != expectedStdout {
+		t.Errorf("expected %q on stdout, got %q", expectedStdout, stdout.String())
+	}
+	if stderr.String() != "first line\nsecond line\n" {
+		t.Errorf("expected the multi-line message on stderr, got %q", stderr.String())
+	}
+	if elapsed < 40*time.Millisecond || elapsed > time.Second {
+		t.Errorf("expected the run to wait between lines and return after the last one, took %v", elapsed)
+	}
+}
+
+func TestRunStopped(t *testing.T) {
+	var stdout bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := Run(ctx, Sequence{Steps: []Step{{Message: "tick", Repetition: 100, Interval: 0.01}}, Jitter: 0.5}, &stdout, &stdout)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a sequence stopped before its end to fail, got %v", err)
+	}
+	if lines := strings.Count(stdout.String(), "tick\n"); lines < 2 || lines > 20 {
+		t.Errorf("expected a few lines before the stop, got %d", lines)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := Run(ctx, Sequence{Steps: []Step{{Message: "tick", Repetition: 1, Interval: 0.01}}, Loop: true}, &stdout, &stdout); err != nil {
+		t.Errorf("expected a stopped loop not to fail, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, sequence := range []Sequence{
+		{},
+		{Steps: []Step{{Message: "a"}}},
+		{Steps: []Step{{Message: "a", Repetition: 1, Stream: "stdlog"}}},
+		{Steps: []Step{{Message: "a", Repetition: 1, Interval: -1}}},
+		{Steps: []Step{{Message: "a", Repetition: 1}}, Jitter: 2},
+	} {
+		if err := sequence.Validate(); err == nil {
+			t.Errorf("expected error for %+v, got nil", sequence)
+		}
+	}
+
+	encoded, err := Sequence{Steps: []Step{{Message: "a\tb", Repetition: 1}}, Loop: true}.Encode()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	sequence, err := ParseSequence([]byte(encoded))
+	if err != nil || sequence.Validate() != nil || sequence.Steps[0].Message != "a\tb" || !sequence.Loop {
+		t.Errorf("expected the sequence to survive encoding, got %+v (%v)", sequence, err)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 500000000, time.UTC)
+	hostname, _ := os.Hostname()
+	pid := strconv.Itoa(os.Getpid())
+	event := Event{ID: "DISK.FULL", Flag: true, Severity: "WARNING", Text: `disk "sda" full`, Source: "checkout"}
+
+	for _, test := range []struct {
+		format   Format
+		expected string
+	}{
+		{Format{}, `{"ID":"DISK.FULL","Flag":true,"Severity":"WARNING","Text":"disk \"sda\" full"}`},
+		{Format{Kind: FormatJSON, TimestampField: "time", TimestampFormat: TimestampUnix}, `{"time":1714557600,"ID":"DISK.FULL","Flag":true,"Severity":"WARNING","Text":"disk \"sda\" full"}`},
+		{Format{Kind: FormatText}, `2024-05-01T10:00:00.5Z WARN [DISK.FULL] disk "sda" full`},
+		{Format{Kind: FormatLogfmt, TimestampFormat: "2006-01-02 15:04:05"}, `ts="2024-05-01 10:00:00" level=warn id=DISK.FULL source=checkout flag=true msg="disk \"sda\" full"`},
+		{Format{Kind: FormatECS, TimestampFormat: TimestampRFC3339}, `{"@timestamp":"2024-05-01T10:00:00Z","log.level":"warn","message":"disk \"sda\" full","ecs.version":"8.11.0","event.code":"DISK.FULL","service.name":"checkout","labels":{"flag":"true"}}`},
+		{Format{Kind: FormatOTel}, `{"Timestamp":1714557600500000000,"SeverityText":"WARN","SeverityNumber":13,"Body":"disk \"sda\" full","Attributes":{"event.id":"DISK.FULL","flag":true},"Resource":{"service.name":"checkout"}}`},
+		{Format{Kind: FormatBunyan}, `{"v":0,"level":40,"time":"2024-05-01T10:00:00.5Z","name":"checkout","hostname":"` + hostname + `","pid":` + pid + `,"id":"DISK.FULL","flag":true,"msg":"disk \"sda\" full"}`},
+		{Format{Kind: FormatPino, TimestampField: "ts"}, `{"level":40,"ts":1714557600500,"name":"checkout","hostname":"` + hostname + `","pid":` + pid + `,"id":"DISK.FULL","flag":true,"msg":"disk \"sda\" full"}`},
+		{Format{Kind: FormatTemplate, Template: `{{.Timestamp}}|{{.Level}}|{{.ID}}|{{.Text}}`}, `2024-05-01T10:00:00.5Z|warn|DISK.FULL|disk "sda" full`},
+	} {
+		if err := test.format.Validate(); err != nil {
+			t.Errorf("expected no error for %+v, got %v", test.format, err)
+		}
+		line, err := test.format.Render(event, now)
+		if err != nil || line != test.expected {
+			t.Errorf("expected %s with %+v, got %s (%v)", test.expected, test.format, line, err)
+		}
+		if match := test.format.TextMatch(event.Text); !strings.Contains(line, match) {
+			t.Errorf("expected %s to contain %s", line, match)
+		}
+	}
+
+	for _, format := range []Format{{Kind: "yaml"}, {Kind: FormatTemplate, Template: "{{.Text"}, {Kind: FormatText, Template: "{{.Text}}"}} {
+		if err := format.Validate(); err == nil {
+			t.Errorf("expected error for %+v, got nil", format)
+		}
+	}
+
+	validated := Format{Kind: FormatTemplate, Template: "{{.ID}}"}
+	if err := validated.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	validated.Template = "{{.Text}}"
+	if line, err := validated.Render(event, now); err != nil || line != "DISK.FULL" {
+		t.Errorf("expected the template parsed by Validate to be rendered, got %s (%v)", line, err)
+	}
+	if line, err := (Format{Kind: FormatTemplate, Template: "{{.Text}}"}).Render(event, now); err != nil || line != event.Text {
+		t.Errorf("expected a format which was not validated to parse its template, got %s (%v)", line, err)
+	}
+}
+
+func TestRunEvents(t *testing.T) {
+	var stdout bytes.Buffer
+	sequence := Sequence{
+		Steps:  []Step{{Event: &Event{ID: "E1", Severity: "error", Text: "boom"}, Repetition: 2}},
+		Format: Format{Kind: FormatLogfmt, TimestampFormat: TimestampUnix},
+	}
+	if err := Run(context.Background(), sequence, &stdout, &stdout); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ts=1") || !strings.HasSuffix(lines[0], " level=error id=E1 flag=false msg=boom") {
+		t.Errorf("expected two logfmt lines rendered at emission time, got %q", stdout.String())
+	}
+}
+
+func TestSyslogMessage(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 500000000, time.UTC)
+	pid := strconv.Itoa(os.Getpid())
+
+	syslog := Syslog{Address: "rsyslog:514", Facility: "local0", Hostname: "pusher host", StructuredData: `[origin@32473 env="staging"]`}
+	expected := `<132>1 2024-05-01T10:00:00.5Z pusher_host checkout ` + pid + ` DISK.FULL [origin@32473 env="staging"] disk full`
+	if message := syslog.Message("WARNING", "checkout", "DISK.FULL", "disk full", now); message != expected {
+		t.Errorf("expected %s, got %s", expected, message)
+	}
+
+	precise := time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.FixedZone("CEST", 2*60*60))
+	expected = `<132>1 2024-05-01T10:00:00.123456+02:00 pusher_host checkout ` + pid + ` - [origin@32473 env="staging"] disk full`
+	if message := syslog.Message("WARNING", "checkout", "", "disk full", precise); message != expected {
+		t.Errorf("expected a TIME-SECFRAC of at most 6 digits %s, got %s", expected, message)
+	}
+
+	syslog = Syslog{Address: "rsyslog:514", Protocol: SyslogRFC3164, Hostname: "host", AppName: "pusher"}
+	expected = `<11>May  1 10:00:00 host pusher[` + pid + `]: disk full`
+	if message := syslog.Message("error", "checkout", "", "disk full", now); message != expected {
+		t.Errorf("expected %s, got %s", expected, message)
+	}
+
+	for severity, expected := range map[string]int{"EMERGENCY": 0, "critical": 2, "Warning": 4, "notice": 5, "INFO": 6, "unknown": 6, "debug": 7} {
+		if got := SyslogSeverity(severity); got != expected {
+			t.Errorf("expected severity %d for %s, got %d", expected, severity, got)
+		}
+	}
+
+	for _, syslog := range []Syslog{
+		{},
+		{Address: "rsyslog"},
+		{Address: "rsyslog:514", Network: "quic"},
+		{Address: "rsyslog:514", Protocol: "rfc9999"},
+		{Address: "rsyslog:514", Framing: "length"},
+		{Address: "rsyslog:514", Facility: "local9"},
+		{Address: "rsyslog:514", StructuredData: "env=staging"},
+		{Address: "rsyslog:514", Network: SyslogTLS, CA: "not a certificate"},
+	} {
+		if err := syslog.Validate(); err == nil {
+			t.Errorf("expected error for %+v, got nil", syslog)
+		}
+	}
+}
+
+func TestRunSyslog(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		connection, err := listener.Accept()
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		defer connection.Close()
+		data, _ := io.ReadAll(connection)
+		received <- string(data)
+	}()
+
+	sequence := Sequence{
+		Steps:  []Step{{Event: &Event{ID: "E1", Severity: "error", Text: "boom", Source: "checkout"}, Repetition: 2}},
+		Format: Format{Kind: FormatText, TimestampFormat: TimestampUnix},
+		Syslog: &Syslog{Address: listener.Addr().String(), Network: SyslogTCP, Hostname: "host"},
+	}
+	var stdout bytes.Buffer
+	if err := Run(context.Background(), sequence, &stdout, &stdout); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected nothing on stdout, got %q", stdout.String())
+	}
+
+	data := <-received
+	for i := 0; i < 2; i++ {
+		length, rest, found := strings.Cut(data, " ")
+		size, err := strconv.Atoi(length)
+		if !found || err != nil || size > len(rest) {
+			t.Fatalf("expected an octet-counted message, got %q", data)
+		}
+		message := rest[:size]
+		if !strings.HasPrefix(message, "<11>1 ") || !strings.Contains(message, " host checkout ") || !strings.Contains(message, " E1 - ") || !strings.HasSuffix(message, "ERROR [E1] boom") {
+			t.Errorf("expected an rfc5424 error message, got %q", message)
+		}
+		data = rest[size:]
+	}
+	if data != "" {
+		t.Errorf("expected two messages, got %q left", data)
+	}
+
+	connection, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer connection.Close()
+	sequence.Syslog = &Syslog{Address: connection.LocalAddr().String(), Protocol: SyslogRFC3164, Hostname: "host"}
+	sequence.Steps[0].Repetition = 1
+	if err := Run(context.Background(), sequence, &stdout, &stdout); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	buffer := make([]byte, 1024)
+	connection.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := connection.ReadFrom(buffer)
+	if err != nil || !strings.HasPrefix(string(buffer[:n]), "<11>") || !strings.Contains(string(buffer[:n]), " host checkout[") || !strings.HasSuffix(string(buffer[:n]), " ERROR [E1] boom") {
+		t.Errorf("expected one rfc3164 datagram, got %q (%v)", buffer[:n], err)
+	}
+}
+
+func TestRunLoki(t *testing.T) {
+	type push struct {
+		path, contentType, tenant string
+		body                      []byte
+	}
+	pushes := make(chan push, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pushes <- push{r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("X-Scope-OrgID"), body}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "stream has 16 labels, limit 15", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sequence := Sequence{
+		Steps:  []Step{{Event: &Event{ID: "E1", Severity: "error", Text: "boom"}, Repetition: 1}},
+		Format: Format{Kind: FormatText, TimestampFormat: TimestampUnix},
+		Loki: &Loki{
+			URL:      server.URL,
+			Encoding: LokiJSON,
+			Labels:   map[string]string{"obs-pusher": "events", "app.kubernetes.io/name": "checkout"},
+			TenantID: "team-a",
+			Headers:  map[string]string{"Authorization": "Bearer token"},
+		},
+	}
+	if err := Run(context.Background(), sequence, io.Discard, io.Discard); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	received := <-pushes
+	var request struct {
+		Streams []struct {
+			Stream map[string]string
+			Values [][]string
+		}
+	}
+	if err := json.Unmarshal(received.body, &request); err != nil {
+		t.Fatalf("expected a JSON push request, got %s (%v)", received.body, err)
+	}
+	if received.path != LokiPushPath || received.contentType != "application/json" || received.tenant != "team-a" {
+		t.Errorf("expected a JSON push of tenant team-a on %s, got %+v", LokiPushPath, received)
+	}
+	if len(request.Streams) != 1 || request.Streams[0].Stream["obs_pusher"] != "events" || request.Streams[0].Stream["app_kubernetes_io_name"] != "checkout" ||
+		len(request.Streams[0].Values) != 1 || !strings.HasSuffix(request.Streams[0].Values[0][1], " ERROR [E1] boom") {
+		t.Errorf("expected one sanitized stream with the line, got %s", received.body)
+	}
+
+	sequence.Loki.URL = server.URL + "/custom/push"
+	sequence.Loki.Encoding = ""
+	now := time.Now()
+	if err := Run(context.Background(), sequence, io.Discard, io.Discard); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	received = <-pushes
+	decoded, err := snappy.Decode(nil, received.body)
+	if received.path != "/custom/push" || received.contentType != "application/x-protobuf" || err != nil {
+		t.Fatalf("expected a snappy protobuf push on /custom/push, got %+v (%v)", received, err)
+	}
+	stream := protobufField(t, decoded, 1)
+	if labels := string(protobufField(t, stream, 1)); labels != `{app_kubernetes_io_name="checkout", obs_pusher="events"}` {
+		t.Errorf("expected sorted stream labels, got %s", labels)
+	}
+	entry := protobufField(t, stream, 2)
+	if line := string(protobufField(t, entry, 2)); !strings.HasSuffix(line, " ERROR [E1] boom") {
+		t.Errorf("expected the line in the entry, got %s", line)
+	}
+	seconds, _ := protowire.ConsumeVarint(protobufField(t, entry, 1)[1:])
+	if int64(seconds) < now.Unix() || int64(seconds) > now.Unix()+1 {
+		t.Errorf("expected the entry to be stamped at emission time, got %d", seconds)
+	}
+
+	encoded, err := sequence.Encode()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if strings.Contains(encoded, "team-a") || strings.Contains(encoded, "Bearer") {
+		t.Errorf("expected the credentials to be left out of the encoded sequence, got %s", encoded)
+	}
+	env, err := sequence.Loki.CredentialsEnv()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	decodedSequence, err := ParseSequence([]byte(encoded))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := decodedSequence.Loki.SetCredentialsFromEnv(func(name string) string { return env[name] }); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if decodedSequence.Loki.TenantID != "team-a" || decodedSequence.Loki.Headers["Authorization"] != "Bearer token" {
+		t.Errorf("expected the credentials of the environment, got %+v", decodedSequence.Loki)
+	}
+	if err := decodedSequence.Loki.SetCredentialsFromEnv(func(string) string { return "{" }); err == nil {
+		t.Errorf("expected error for invalid headers, got nil")
+	}
+
+	sequence.Loki.Headers = nil
+	if err := Run(context.Background(), sequence, io.Discard, io.Discard); err == nil || !strings.Contains(err.Error(), "limit 15") {
+		t.Errorf("expected the Loki refusal, got %v", err)
+	}
+	<-pushes
+
+	for _, loki := range []Loki{
+		{Labels: map[string]string{"job": "a"}},
+		{URL: "loki:3100", Labels: map[string]string{"job": "a"}},
+		{URL: "http://loki:3100", Encoding: "xml", Labels: map[string]string{"job": "a"}},
+		{URL: "http://loki:3100"},
+	} {
+		if err := loki.Validate(); err == nil {
+			t.Errorf("expected error for %+v, got nil", loki)
+		}
+	}
+	both := Sequence{Steps: sequence.Steps, Loki: sequence.Loki, Syslog: &Syslog{Address: "rsyslog:514"}}
+	if err := both.Validate(); err == nil {
+		t.Errorf("expected error for a sequence sent to syslog and Loki, got nil")
+	}
+}
+
+// protobufField returns the first bytes field of the message with the number
+func protobufField(t *testing.T, message []byte, number protowire.Number) []byte {
+	t.Helper()
+	for len(message) > 0 {
+		field, fieldType, n := protowire.ConsumeTag(message)
+		message = message[n:]
+		if field == number && fieldType == protowire.BytesType {
+			value, _ := protowire.ConsumeBytes(message)
+			return value
+		}
+		message = message[protowire.ConsumeFieldValue(field, fieldType, message):]
+	}
+	t.Fatalf("expected field %d in the message", number)
+	return nil
+}
@@ -0,0 +1,307 @@
+package emitter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Output formats of the events
+const (
+	FormatJSON     = "json"
+	FormatText     = "text"
+	FormatLogfmt   = "logfmt"
+	FormatECS      = "ecs"
+	FormatOTel     = "otel"
+	FormatBunyan   = "bunyan"
+	FormatPino     = "pino"
+	FormatTemplate = "template"
+)
+
+// Timestamp formats, any other value is used as a Go time layout
+const (
+	TimestampRFC3339     = "rfc3339"
+	TimestampRFC3339Nano = "rfc3339nano"
+	TimestampUnix        = "unix"
+	TimestampUnixMilli   = "unix-ms"
+	TimestampUnixNano    = "unix-ns"
+)
+
+// Formats lists the output formats
+var Formats = []string{FormatJSON, FormatText, FormatLogfmt, FormatECS, FormatOTel, FormatBunyan, FormatPino, FormatTemplate}
+
+// Event is a dictionary event, formatted when it is written so its timestamp is the emission time
+type Event struct {
+	ID       string `json:"id,omitempty"`
+	Flag     bool   `json:"flag,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Text     string `json:"text"`
+	// Source is the application writing the event, e.g. the bunyan name or the ECS service.name
+	Source string `json:"source,omitempty"`
+}
+
+// Format describes how events are written
+type Format struct {
+	// Kind is one of Formats, json when empty
+	Kind string `json:"kind,omitempty"`
+	// TimestampField names the timestamp, the default of the kind when empty. The
+	// json kind only has a timestamp when it is set
+	TimestampField string `json:"timestampField,omitempty"`
+	// TimestampFormat is rfc3339, rfc3339nano, unix, unix-ms, unix-ns or a Go time
+	// layout, rfc3339nano when empty except for pino (unix-ms) and otel (unix-ns)
+	TimestampFormat string `json:"timestampFormat,omitempty"`
+	// Template is the Go template of the template kind, executed with TemplateData
+	Template string `json:"template,omitempty"`
+
+	// template is the parsed Template, kept by Validate so lines do not parse it again
+	template *template.Template
+}
+
+// TemplateData is what the template of the template format is executed with
+type TemplateData struct {
+	Event
+	// Timestamp is the emission time in the timestamp format
+	Timestamp string
+	Time      time.Time
+	// Level is the normalized severity: trace, debug, info, warn, error or fatal
+	Level string
+}
+
+// Validate checks the format is known and its template parses, keeping the
+// parsed template for Render
+func (f *Format) Validate() error {
+	switch f.Kind {
+	case "", FormatJSON, FormatText, FormatLogfmt, FormatECS, FormatOTel, FormatBunyan, FormatPino:
+		if f.Template != "" {
+			return fmt.Errorf("a template is only used by the %s format", FormatTemplate)
+		}
+		return nil
+	case FormatTemplate:
+		eventTemplate, err := template.New("event").Parse(f.Template)
+		if err != nil {
+			return fmt.Errorf("invalid event template: %w", err)
+		}
+		f.template = eventTemplate
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q, expected one of %s", f.Kind, strings.Join(Formats, ", "))
+	}
+}
+
+// Levels of the normalized severities with their OpenTelemetry and bunyan numbers
+var levels = map[string][2]int{
+	"trace": {1, 10},
+	"debug": {5, 20},
+	"info":  {9, 30},
+	"warn":  {13, 40},
+	"error": {17, 50},
+	"fatal": {21, 60},
+}
+
+// Level normalizes a dictionary severity to trace, debug, info, warn, error or
+// fatal, unknown severities being info
+func Level(severity string) string {
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case "trace", "finest", "finer":
+		return "trace"
+	case "debug", "fine", "config":
+		return "debug"
+	case "warn", "warning":
+		return "warn"
+	case "error", "err", "severe":
+		return "error"
+	case "fatal", "critical", "crit", "alert", "emerg", "emergency", "panic":
+		return "fatal"
+	default:
+		return "info"
+	}
+}
+
+// field is a key and its value, JSON formats keeping the fields in order
+type field struct {
+	key   string
+	value any
+}
+
+// Render formats the event written at the given time, with the template parsed
+// by Validate when the format was validated
+func (f Format) Render(event Event, now time.Time) (string, error) {
+	level := Level(event.Severity)
+	timestampField := f.TimestampField
+	var timestamp any
+	switch f.Kind {
+	case FormatPino:
+		timestamp = f.timestamp(now, TimestampUnixMilli)
+	case FormatOTel:
+		timestamp = f.timestamp(now, TimestampUnixNano)
+	default:
+		timestamp = f.timestamp(now, TimestampRFC3339Nano)
+	}
+
+	switch f.Kind {
+	case "", FormatJSON:
+		var fields []field
+		if timestampField != "" {
+			fields = append(fields, field{timestampField, timestamp})
+		}
+		return encodeFields(append(fields,
+			field{"ID", event.ID}, field{"Flag", event.Flag}, field{"Severity", event.Severity}, field{"Text", event.Text}))
+	case FormatText:
+		line := fmt.Sprintf("%v %s ", timestamp, strings.ToUpper(level))
+		if event.ID != "" {
+			line += "[" + event.ID + "] "
+		}
+		return line + event.Text, nil
+	case FormatLogfmt:
+		fields := []field{{defaultString(timestampField, "ts"), timestamp}, {"level", level}}
+		if event.ID != "" {
+			fields = append(fields, field{"id", event.ID})
+		}
+		if event.Source != "" {
+			fields = append(fields, field{"source", event.Source})
+		}
+		fields = append(fields, field{"flag", event.Flag}, field{"msg", event.Text})
+		var pairs []string
+		for _, field := range fields {
+			pairs = append(pairs, field.key+"="+logfmtValue(fmt.Sprint(field.value)))
+		}
+		return strings.Join(pairs, " "), nil
+	case FormatECS:
+		fields := []field{{defaultString(timestampField, "@timestamp"), timestamp}, {"log.level", level}, {"message", event.Text}, {"ecs.version", "8.11.0"}}
+		if event.ID != "" {
+			fields = append(fields, field{"event.code", event.ID})
+		}
+		if event.Source != "" {
+			fields = append(fields, field{"service.name", event.Source})
+		}
+		fields = append(fields, field{"labels", map[string]string{"flag": strconv.FormatBool(event.Flag)}})
+		return encodeFields(fields)
+	case FormatOTel:
+		attributes := map[string]any{"flag": event.Flag}
+		if event.ID != "" {
+			attributes["event.id"] = event.ID
+		}
+		fields := []field{
+			{defaultString(timestampField, "Timestamp"), timestamp},
+			{"SeverityText", strings.ToUpper(level)},
+			{"SeverityNumber", levels[level][0]},
+			{"Body", event.Text},
+			{"Attributes", attributes},
+		}
+		if event.Source != "" {
+			fields = append(fields, field{"Resource", map[string]string{"service.name": event.Source}})
+		}
+		return encodeFields(fields)
+	case FormatBunyan, FormatPino:
+		hostname, _ := os.Hostname()
+		var fields []field
+		if f.Kind == FormatBunyan {
+			fields = append(fields, field{"v", 0})
+		}
+		fields = append(fields,
+			field{"level", levels[level][1]},
+			field{defaultString(timestampField, "time"), timestamp},
+			field{"name", event.Source},
+			field{"hostname", hostname},
+			field{"pid", os.Getpid()},
+		)
+		if event.ID != "" {
+			fields = append(fields, field{"id", event.ID})
+		}
+		return encodeFields(append(fields, field{"flag", event.Flag}, field{"msg", event.Text}))
+	case FormatTemplate:
+		eventTemplate := f.template
+		if eventTemplate == nil {
+			var err error
+			eventTemplate, err = template.New("event").Parse(f.Template)
+			if err != nil {
+				return "", fmt.Errorf("invalid event template: %w", err)
+			}
+		}
+		var line bytes.Buffer
+		data := TemplateData{Event: event, Timestamp: fmt.Sprint(timestamp), Time: now, Level: level}
+		if err := eventTemplate.Execute(&line, data); err != nil {
+			return "", fmt.Errorf("error executing event template: %w", err)
+		}
+		return line.String(), nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected one of %s", f.Kind, strings.Join(Formats, ", "))
+	}
+}
+
+// TextMatch returns the text of an event as written in a line of the format,
+// e.g. escaped in JSON, to search the line in a log backend
+func (f Format) TextMatch(text string) string {
+	switch f.Kind {
+	case FormatText, FormatTemplate:
+		return text
+	case FormatLogfmt:
+		quoted := logfmtValue(text)
+		if quoted != text {
+			return quoted[1 : len(quoted)-1]
+		}
+		return text
+	default:
+		encoded, _ := json.Marshal(text)
+		return string(encoded[1 : len(encoded)-1])
+	}
+}
+
+// timestamp returns the time in the timestamp format, unix timestamps being numbers
+func (f Format) timestamp(now time.Time, defaultFormat string) any {
+	switch defaultString(f.TimestampFormat, defaultFormat) {
+	case TimestampRFC3339:
+		return now.UTC().Format(time.RFC3339)
+	case TimestampRFC3339Nano:
+		return now.UTC().Format(time.RFC3339Nano)
+	case TimestampUnix:
+		return now.Unix()
+	case TimestampUnixMilli:
+		return now.UnixMilli()
+	case TimestampUnixNano:
+		return now.UnixNano()
+	default:
+		return now.Format(f.TimestampFormat)
+	}
+}
+
+// encodeFields encodes the fields as a JSON object keeping their order
+func encodeFields(fields []field) (string, error) {
+	var line strings.Builder
+	line.WriteString("{")
+	for i, field := range fields {
+		key, _ := json.Marshal(field.key)
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return "", fmt.Errorf("error encoding %s: %w", field.key, err)
+		}
+		if i > 0 {
+			line.WriteString(",")
+		}
+		line.Write(key)
+		line.WriteString(":")
+		line.Write(value)
+	}
+	line.WriteString("}")
+	return line.String(), nil
+}
+
+// logfmtValue quotes values which are empty or hold spaces, quotes, equal signs or control characters
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =") || strconv.Quote(value) != `"`+value+`"` {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+func defaultString(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,234 @@
+package emitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Loki push API encodings
+const (
+	LokiProtobuf = "protobuf"
+	LokiJSON     = "json"
+)
+
+// LokiPushPath is the path of the Loki push API, added to addresses without a path
+const LokiPushPath = "/loki/api/v1/push"
+
+// Environment variables the Loki credentials are given to a pod with, as they
+// are not part of the encoded sequence
+const (
+	LokiTenantIDEnv = "OBS_PUSHER_LOKI_TENANT_ID"
+	// LokiHeadersEnv holds the headers as a JSON object
+	LokiHeadersEnv = "OBS_PUSHER_LOKI_HEADERS"
+)
+
+// Loki pushes the lines of a sequence to the Loki push API instead of stdout and stderr
+type Loki struct {
+	// URL of the push API, or of Loki to which the push API path is added
+	URL string `json:"url"`
+	// Encoding is protobuf (snappy compressed, default) or json
+	Encoding string `json:"encoding,omitempty"`
+	// Labels of the stream, whose names are sanitized to [a-zA-Z_][a-zA-Z0-9_]*
+	Labels map[string]string `json:"labels"`
+	// TenantID is sent as the X-Scope-OrgID header when set
+	TenantID string `json:"-"`
+	// Headers added to every request, e.g. an Authorization header
+	Headers map[string]string `json:"-"`
+}
+
+// CredentialsEnv returns the environment variables holding the tenant and the
+// headers, empty when there are none
+func (l Loki) CredentialsEnv() (map[string]string, error) {
+	env := make(map[string]string)
+	if l.TenantID != "" {
+		env[LokiTenantIDEnv] = l.TenantID
+	}
+	if len(l.Headers) > 0 {
+		headers, err := json.Marshal(l.Headers)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding Loki headers: %w", err)
+		}
+		env[LokiHeadersEnv] = string(headers)
+	}
+	return env, nil
+}
+
+// SetCredentialsFromEnv sets the tenant and the headers of the environment
+// variables returned by getenv, keeping the current ones when they are unset
+func (l *Loki) SetCredentialsFromEnv(getenv func(string) string) error {
+	if tenantID := getenv(LokiTenantIDEnv); tenantID != "" {
+		l.TenantID = tenantID
+	}
+	if headers := getenv(LokiHeadersEnv); headers != "" {
+		if err := json.Unmarshal([]byte(headers), &l.Headers); err != nil {
+			return fmt.Errorf("invalid %s: %w", LokiHeadersEnv, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the Loki settings
+func (l Loki) Validate() error {
+	if _, err := l.pushURL(); err != nil {
+		return err
+	}
+	switch l.Encoding {
+	case "", LokiProtobuf, LokiJSON:
+	default:
+		return fmt.Errorf("unknown Loki encoding %q, expected %s or %s", l.Encoding, LokiProtobuf, LokiJSON)
+	}
+	if len(l.Labels) == 0 {
+		return fmt.Errorf("a Loki stream needs at least one label")
+	}
+	return nil
+}
+
+// pushURL returns the URL of the push API
+func (l Loki) pushURL() (string, error) {
+	if l.URL == "" {
+		return "", fmt.Errorf("a Loki push URL is required")
+	}
+	pushURL, err := url.Parse(l.URL)
+	if err != nil || (pushURL.Scheme != "http" && pushURL.Scheme != "https") || pushURL.Host == "" {
+		return "", fmt.Errorf("invalid Loki push URL %q, expected an http or https address", l.URL)
+	}
+	if pushURL.Path == "" || pushURL.Path == "/" {
+		pushURL.Path = LokiPushPath
+	}
+	return pushURL.String(), nil
+}
+
+// StreamLabels returns the stream labels in the Prometheus format, sorted by name
+func (l Loki) StreamLabels() string {
+	labels := make(map[string]string, len(l.Labels))
+	for name, value := range l.Labels {
+		labels[lokiLabelName(name)] = value
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+strconv.Quote(labels[name]))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// lokiLabelName replaces the characters Loki refuses in label names, such as the
+// dashes and dots of pod labels, with underscores
+func lokiLabelName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return "_" + name
+	}
+	return name
+}
+
+// Body encodes the push request of a line written at the given time, returning its content type
+func (l Loki) Body(line string, now time.Time) ([]byte, string, error) {
+	if l.Encoding == LokiJSON {
+		labels := make(map[string]string, len(l.Labels))
+		for name, value := range l.Labels {
+			labels[lokiLabelName(name)] = value
+		}
+		body, err := json.Marshal(map[string]any{"streams": []any{map[string]any{
+			"stream": labels,
+			"values": [][]string{{strconv.FormatInt(now.UnixNano(), 10), line}},
+		}}})
+		if err != nil {
+			return nil, "", fmt.Errorf("error encoding Loki push request: %w", err)
+		}
+		return body, "application/json", nil
+	}
+
+	// logproto.PushRequest with one StreamAdapter holding one EntryAdapter
+	var timestamp []byte
+	timestamp = protowire.AppendTag(timestamp, 1, protowire.VarintType)
+	timestamp = protowire.AppendVarint(timestamp, uint64(now.Unix()))
+	timestamp = protowire.AppendTag(timestamp, 2, protowire.VarintType)
+	timestamp = protowire.AppendVarint(timestamp, uint64(now.Nanosecond()))
+	var entry []byte
+	entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+	entry = protowire.AppendBytes(entry, timestamp)
+	entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+	entry = protowire.AppendString(entry, line)
+	var stream []byte
+	stream = protowire.AppendTag(stream, 1, protowire.BytesType)
+	stream = protowire.AppendString(stream, l.StreamLabels())
+	stream = protowire.AppendTag(stream, 2, protowire.BytesType)
+	stream = protowire.AppendBytes(stream, entry)
+	var request []byte
+	request = protowire.AppendTag(request, 1, protowire.BytesType)
+	request = protowire.AppendBytes(request, stream)
+	return snappy.Encode(nil, request), "application/x-protobuf", nil
+}
+
+// lokiOutput pushes every line to Loki as it is written
+type lokiOutput struct {
+	config  Loki
+	pushURL string
+	client  *http.Client
+}
+
+func newLokiOutput(config Loki) (*lokiOutput, error) {
+	pushURL, err := config.pushURL()
+	if err != nil {
+		return nil, err
+	}
+	return &lokiOutput{config: config, pushURL: pushURL, client: http.DefaultClient}, nil
+}
+
+func (o *lokiOutput) Write(ctx context.Context, step Step, line string, now time.Time) error {
+	body, contentType, err := o.config.Body(line, now)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, o.pushURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating Loki push request: %w", err)
+	}
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set("User-Agent", "obs-pusher")
+	if o.config.TenantID != "" {
+		request.Header.Set("X-Scope-OrgID", o.config.TenantID)
+	}
+	for name, value := range o.config.Headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := o.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error sending Loki push request: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		// Loki explains refused streams, e.g. too many labels, in the body
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("push to %s failed with status %s: %s", o.pushURL, response.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+func (o *lokiOutput) Close() error {
+	return nil
+}
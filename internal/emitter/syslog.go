@@ -0,0 +1,222 @@
+package emitter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Syslog protocols, networks and framings
+const (
+	SyslogRFC5424         = "rfc5424"
+	SyslogRFC3164         = "rfc3164"
+	SyslogUDP             = "udp"
+	SyslogTCP             = "tcp"
+	SyslogTLS             = "tls"
+	FramingOctetCounting  = "octet-counting"
+	FramingNonTransparent = "non-transparent"
+)
+
+const (
+	syslogDefaultFacility   = "user"
+	syslogDefaultAppName    = "obs-pusher"
+	syslogNilValue          = "-"
+	syslogMaxAppNameLength  = 48
+	syslogMaxHostnameLength = 255
+	// syslogTimestamp is RFC 3339 with the at most 6 digits of TIME-SECFRAC
+	syslogTimestamp = "2006-01-02T15:04:05.999999Z07:00"
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11, "ntp": 12, "security": 13, "console": 14, "solaris-cron": 15,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Syslog sends the lines of a sequence to a syslog server instead of stdout and stderr
+type Syslog struct {
+	// Address of the syslog server as host:port
+	Address string `json:"address"`
+	// Network is udp (default), tcp or tls
+	Network string `json:"network,omitempty"`
+	// Protocol is rfc5424 (default) or rfc3164
+	Protocol string `json:"protocol,omitempty"`
+	// Framing of TCP and TLS messages, octet-counting (default) or non-transparent (newline terminated)
+	Framing string `json:"framing,omitempty"`
+	// Facility name such as user (default), daemon, auth or local0 to local7
+	Facility string `json:"facility,omitempty"`
+	// AppName is the APP-NAME or TAG, the event source or obs-pusher when empty
+	AppName string `json:"appName,omitempty"`
+	// Hostname of the messages, the host name of the machine when empty
+	Hostname string `json:"hostname,omitempty"`
+	// StructuredData of RFC 5424 messages e.g [origin@32473 env="staging"]
+	StructuredData string `json:"structuredData,omitempty"`
+	// CA is the PEM certificate authority checking the TLS server, the system ones when empty
+	CA string `json:"ca,omitempty"`
+	// ServerName checked on the TLS server certificate, the address host when empty
+	ServerName         string `json:"serverName,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+// Validate checks the syslog settings
+func (s Syslog) Validate() error {
+	if s.Address == "" {
+		return fmt.Errorf("a syslog address is required")
+	}
+	if _, _, err := net.SplitHostPort(s.Address); err != nil {
+		return fmt.Errorf("invalid syslog address %q: %w", s.Address, err)
+	}
+	switch s.Network {
+	case "", SyslogUDP, SyslogTCP, SyslogTLS:
+	default:
+		return fmt.Errorf("unknown syslog network %q, expected %s, %s or %s", s.Network, SyslogUDP, SyslogTCP, SyslogTLS)
+	}
+	switch s.Protocol {
+	case "", SyslogRFC5424, SyslogRFC3164:
+	default:
+		return fmt.Errorf("unknown syslog protocol %q, expected %s or %s", s.Protocol, SyslogRFC5424, SyslogRFC3164)
+	}
+	switch s.Framing {
+	case "", FramingOctetCounting, FramingNonTransparent:
+	default:
+		return fmt.Errorf("unknown syslog framing %q, expected %s or %s", s.Framing, FramingOctetCounting, FramingNonTransparent)
+	}
+	if _, found := syslogFacilities[defaultString(s.Facility, syslogDefaultFacility)]; !found {
+		return fmt.Errorf("unknown syslog facility %q", s.Facility)
+	}
+	if s.StructuredData != "" && s.StructuredData != syslogNilValue &&
+		(!strings.HasPrefix(s.StructuredData, "[") || !strings.HasSuffix(s.StructuredData, "]")) {
+		return fmt.Errorf("invalid syslog structured data %q, expected elements such as [id@32473 key=\"value\"]", s.StructuredData)
+	}
+	if s.CA != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(s.CA)) {
+		return fmt.Errorf("the syslog CA holds no PEM certificate")
+	}
+	return nil
+}
+
+// SyslogSeverity maps a dictionary severity to a syslog severity, from 0
+// (emergency) to 7 (debug), unknown severities being informational
+func SyslogSeverity(severity string) int {
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case "emerg", "emergency", "panic":
+		return 0
+	case "alert":
+		return 1
+	case "crit", "critical", "fatal":
+		return 2
+	case "err", "error", "severe":
+		return 3
+	case "warn", "warning":
+		return 4
+	case "notice":
+		return 5
+	case "debug", "trace", "fine", "finer", "finest", "config":
+		return 7
+	default:
+		return 6
+	}
+}
+
+// Message returns the syslog message of a line, without framing
+func (s Syslog) Message(severity, appName, msgID, line string, now time.Time) string {
+	priority := syslogFacilities[defaultString(s.Facility, syslogDefaultFacility)]*8 + SyslogSeverity(severity)
+	hostname := s.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	appName = defaultString(s.AppName, defaultString(appName, syslogDefaultAppName))
+
+	if s.Protocol == SyslogRFC3164 {
+		return fmt.Sprintf("<%d>%s %s %s[%d]: %s", priority, now.Format(time.Stamp), syslogField(hostname, syslogMaxHostnameLength), syslogField(appName, 32), os.Getpid(), line)
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s", priority, now.Format(syslogTimestamp),
+		syslogField(hostname, syslogMaxHostnameLength), syslogField(appName, syslogMaxAppNameLength), os.Getpid(),
+		syslogField(msgID, 32), defaultString(s.StructuredData, syslogNilValue), line)
+}
+
+// syslogField replaces spaces and non printable characters of a header field and truncates it, empty fields being -
+func syslogField(value string, maxLength int) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, value)
+	if value == "" {
+		return syslogNilValue
+	}
+	return value[:min(len(value), maxLength)]
+}
+
+// syslogOutput sends the lines to a syslog server, reconnecting once when a stream connection fails
+type syslogOutput struct {
+	config     Syslog
+	connection net.Conn
+}
+
+func newSyslogOutput(ctx context.Context, config Syslog) (*syslogOutput, error) {
+	output := &syslogOutput{config: config}
+	if err := output.connect(ctx); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+func (o *syslogOutput) connect(ctx context.Context) error {
+	var err error
+	switch o.config.Network {
+	case SyslogTLS:
+		tlsConfig := &tls.Config{ServerName: o.config.ServerName, InsecureSkipVerify: o.config.InsecureSkipVerify}
+		if o.config.CA != "" {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			tlsConfig.RootCAs.AppendCertsFromPEM([]byte(o.config.CA))
+		}
+		o.connection, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", o.config.Address)
+	case SyslogTCP:
+		o.connection, err = (&net.Dialer{}).DialContext(ctx, "tcp", o.config.Address)
+	default:
+		o.connection, err = (&net.Dialer{}).DialContext(ctx, "udp", o.config.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("error connecting to syslog server %s: %w", o.config.Address, err)
+	}
+	return nil
+}
+
+func (o *syslogOutput) Write(ctx context.Context, step Step, line string, now time.Time) error {
+	severity, appName, msgID := "", "", ""
+	if step.Event != nil {
+		severity, appName, msgID = step.Event.Severity, step.Event.Source, step.Event.ID
+	}
+	message := o.config.Message(severity, appName, msgID, line, now)
+
+	// UDP sends one message per datagram, TCP frames them
+	if o.config.Network == SyslogTCP || o.config.Network == SyslogTLS {
+		if o.config.Framing == FramingNonTransparent {
+			message += "\n"
+		} else {
+			message = strconv.Itoa(len(message)) + " " + message
+		}
+		if _, err := o.connection.Write([]byte(message)); err == nil {
+			return nil
+		}
+		o.connection.Close()
+		if err := o.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if _, err := o.connection.Write([]byte(message)); err != nil {
+		return fmt.Errorf("error sending syslog message: %w", err)
+	}
+	return nil
+}
+
+func (o *syslogOutput) Close() error {
+	return o.connection.Close()
+}
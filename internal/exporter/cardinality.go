@@ -0,0 +1,123 @@
+package exporter
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/common/model"
+)
+
+// Kinds of high cardinality label values
+const (
+	CardinalityIncrementing = "incrementing"
+	CardinalityUUID         = "uuid"
+)
+
+// Cardinality turns a series into many series differing by one high
+// cardinality label, optionally replacing part of them every churn interval
+type Cardinality struct {
+	// Series is the number of series generated for each label set
+	Series int `json:"series"`
+	// Label is the name of the high cardinality label, defaults to "id"
+	Label string `json:"label,omitempty"`
+	// Values is the kind of label values, incrementing (default) or uuid
+	Values string `json:"values,omitempty"`
+	// ChurnInterval in seconds between two replacements of series
+	ChurnInterval float64 `json:"churnInterval,omitempty"`
+	// ChurnRate is the fraction of the series replaced every churn interval, within [0,1]
+	ChurnRate float64 `json:"churnRate,omitempty"`
+}
+
+func validateCardinality(series *Series) error {
+	cardinality := *series.Cardinality
+	series.Cardinality = &cardinality
+	if cardinality.Series <= 0 {
+		return fmt.Errorf("cardinality series count must be positive on metric %s", series.Name)
+	}
+	if cardinality.Label == "" {
+		cardinality.Label = "id"
+	}
+	if !model.LabelName(cardinality.Label).IsValid() {
+		return fmt.Errorf("invalid cardinality label name %q on metric %s", cardinality.Label, series.Name)
+	}
+	if _, exists := series.Labels[cardinality.Label]; exists {
+		return fmt.Errorf("cardinality label %s is already set on metric %s", cardinality.Label, series.Name)
+	}
+	switch cardinality.Values {
+	case "":
+		cardinality.Values = CardinalityIncrementing
+	case CardinalityIncrementing, CardinalityUUID:
+	default:
+		return fmt.Errorf("unknown cardinality values %q on metric %s, expected incrementing or uuid", cardinality.Values, series.Name)
+	}
+	if cardinality.ChurnRate < 0 || cardinality.ChurnRate > 1 {
+		return fmt.Errorf("cardinality churn rate must be within [0,1] on metric %s", series.Name)
+	}
+	if cardinality.ChurnInterval < 0 {
+		return fmt.Errorf("cardinality churn interval must be positive on metric %s", series.Name)
+	}
+	return nil
+}
+
+// cardinalityFamily is the changing set of series generated from one series
+type cardinalityFamily struct {
+	series    Series
+	states    []*seriesState
+	nextID    int
+	lastChurn time.Time
+}
+
+func newCardinalityFamily(series Series, start time.Time) (*cardinalityFamily, error) {
+	family := &cardinalityFamily{series: series, lastChurn: start}
+	for range series.Cardinality.Series {
+		state, err := family.newState(start)
+		if err != nil {
+			return nil, err
+		}
+		family.states = append(family.states, state)
+	}
+	return family, nil
+}
+
+// newState creates a series with a fresh value for the high cardinality label
+func (f *cardinalityFamily) newState(start time.Time) (*seriesState, error) {
+	series := f.series
+	series.Labels = maps.Clone(f.series.Labels)
+	if series.Labels == nil {
+		series.Labels = make(map[string]string)
+	}
+
+	value := strconv.Itoa(f.nextID)
+	if f.series.Cardinality.Values == CardinalityUUID {
+		value = uuid.NewString()
+	}
+	f.nextID++
+	series.Labels[f.series.Cardinality.Label] = value
+
+	return newSeriesState(series, start)
+}
+
+// churn replaces the oldest series for every churn interval elapsed
+func (f *cardinalityFamily) churn(now time.Time) {
+	cardinality := f.series.Cardinality
+	if cardinality.ChurnInterval <= 0 || cardinality.ChurnRate == 0 {
+		return
+	}
+
+	interval := time.Duration(cardinality.ChurnInterval * float64(time.Second))
+	replaced := int(math.Ceil(cardinality.ChurnRate * float64(len(f.states))))
+	for !f.lastChurn.Add(interval).After(now) {
+		f.lastChurn = f.lastChurn.Add(interval)
+		for range replaced {
+			state, err := f.newState(f.lastChurn)
+			if err != nil {
+				return
+			}
+			f.states = append(f.states[1:], state)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package exporter
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/generator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+// Metric types understood by the exporter
+const (
+	TypeGauge     = "gauge"
+	TypeCounter   = "counter"
+	TypeHistogram = "histogram"
+	TypeSummary   = "summary"
+	TypeUntyped   = "untyped"
+)
+
+// summaryWindow is the number of most recent observations summary quantiles are computed over
+const summaryWindow = 1000
+
+// DefaultQuantiles are the quantiles exposed by summaries when none are configured
+var DefaultQuantiles = []float64{0.5, 0.9, 0.99}
+
+// seriesState holds what has been observed so far for a series
+type seriesState struct {
+	series       Series
+	generator    generator.Generator
+	start        time.Time
+	lastTick     time.Time
+	resetChecked time.Time
+	counter      float64
+	count        uint64
+	sum          float64
+	bucketCounts []uint64
+	observations []float64
+	// created is when the series started accumulating, the start or the last reset
+	created time.Time
+	// exemplars holds the last exemplar of a counter or of every histogram bucket, +Inf last
+	exemplars []*prometheus.Exemplar
+	// exponential buckets of histograms, nil unless enabled
+	exponential *exponentialBuckets
+}
+
+// Collector exposes the configured series as Prometheus metrics.
+//
+// Gauges and untyped series report their generated value at scrape time.
+// Counters are incremented by the generated value and histograms and summaries
+// observe it once every interval, until a scheduled reset brings them back to
+// zero. Series are not collected during their absent windows.
+type Collector struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	start    time.Time
+	now      func() time.Time
+	states   []*seriesState
+	families []*cardinalityFamily
+	// units of the metric families by name
+	units map[string]string
+}
+
+// NewCollector validates the configuration and creates a collector for it
+func NewCollector(config Config) (*Collector, error) {
+	return newCollector(config, time.Now)
+}
+
+func newCollector(config Config, now func() time.Time) (*Collector, error) {
+	interval := time.Second
+	if config.Interval > 0 {
+		interval = time.Duration(config.Interval * float64(time.Second))
+	}
+
+	start := now()
+	collector := &Collector{interval: interval, start: start, now: now, units: make(map[string]string)}
+	seen := make(map[string]bool)
+	for _, configured := range config.Series {
+		for _, series := range expandSeries(configured) {
+			if err := validateSeries(&series); err != nil {
+				return nil, err
+			}
+			key := seriesKey(series)
+			if seen[key] {
+				return nil, fmt.Errorf("duplicate series %s", key)
+			}
+			seen[key] = true
+			if series.Unit != "" {
+				if unit, ok := collector.units[series.Name]; ok && unit != series.Unit {
+					return nil, fmt.Errorf("metric %s has conflicting units %s and %s", series.Name, unit, series.Unit)
+				}
+				collector.units[series.Name] = series.Unit
+			}
+
+			if series.Cardinality != nil {
+				family, err := newCardinalityFamily(series, start)
+				if err != nil {
+					return nil, err
+				}
+				collector.families = append(collector.families, family)
+				continue
+			}
+
+			state, err := newSeriesState(series, start)
+			if err != nil {
+				return nil, err
+			}
+			collector.states = append(collector.states, state)
+		}
+	}
+	return collector, nil
+}
+
+func newSeriesState(series Series, start time.Time) (*seriesState, error) {
+	seriesGenerator, err := newGenerator(series)
+	if err != nil {
+		return nil, err
+	}
+	state := &seriesState{
+		series:       series,
+		generator:    seriesGenerator,
+		start:        start,
+		lastTick:     start,
+		resetChecked: start,
+		bucketCounts: make([]uint64, len(series.Buckets)),
+		created:      start,
+		exemplars:    make([]*prometheus.Exemplar, len(series.Buckets)+1),
+	}
+	if series.Exponential {
+		state.exponential = newExponentialBuckets()
+	}
+	return state, nil
+}
+
+func newGenerator(series Series) (generator.Generator, error) {
+	var spec generator.Spec
+	if series.Generator != nil {
+		spec = *series.Generator
+	}
+	seriesGenerator, err := generator.New(spec, float64(series.Value))
+	if err != nil {
+		return nil, fmt.Errorf("invalid generator on metric %s: %w", series.Name, err)
+	}
+	return seriesGenerator, nil
+}
+
+func validateSeries(series *Series) error {
+	if !model.IsValidMetricName(model.LabelValue(series.Name)) {
+		return fmt.Errorf("invalid metric name: %q", series.Name)
+	}
+	for label := range series.Labels {
+		if !model.LabelName(label).IsValid() {
+			return fmt.Errorf("invalid label name %q on metric %s", label, series.Name)
+		}
+	}
+	if series.Cardinality != nil {
+		if err := validateCardinality(series); err != nil {
+			return err
+		}
+	}
+	if err := validateLifecycle(series); err != nil {
+		return err
+	}
+
+	switch series.Type {
+	case "":
+		series.Type = TypeGauge
+	case TypeGauge, TypeUntyped:
+	case TypeCounter:
+		// Every tick adds the value to the counter, which must never decrease
+		var spec generator.Spec
+		if series.Generator != nil {
+			spec = *series.Generator
+		}
+		lowest := generator.Min(spec, float64(series.Value))
+		if math.IsNaN(float64(series.Value)) || math.IsNaN(lowest) || lowest < 0 {
+			return fmt.Errorf("invalid value on counter %s, counters only increase so values must not be negative, got a minimum of %v", series.Name, lowest)
+		}
+	case TypeHistogram:
+		if len(series.Buckets) == 0 {
+			series.Buckets = prometheus.DefBuckets
+		}
+		for _, bucket := range series.Buckets {
+			if math.IsNaN(bucket) {
+				return fmt.Errorf("invalid NaN bucket on metric %s", series.Name)
+			}
+		}
+		series.Buckets = slices.Clone(series.Buckets)
+		slices.Sort(series.Buckets)
+		series.Buckets = slices.Compact(series.Buckets)
+		if math.IsInf(series.Buckets[len(series.Buckets)-1], 1) {
+			series.Buckets = series.Buckets[:len(series.Buckets)-1]
+		}
+	case TypeSummary:
+		if len(series.Quantiles) == 0 {
+			series.Quantiles = DefaultQuantiles
+		}
+		for _, quantile := range series.Quantiles {
+			if quantile < 0 || quantile > 1 {
+				return fmt.Errorf("invalid quantile %v on metric %s, quantiles must be within [0,1]", quantile, series.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported metric type %q on metric %s", series.Type, series.Name)
+	}
+	if err := validateUnit(series); err != nil {
+		return err
+	}
+	if err := validateExemplars(series); err != nil {
+		return err
+	}
+	return validateExponential(series)
+}
+
+// Describe sends no descriptors, making the collector unchecked so the set of
+// series is allowed to vary between scrapes
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect advances every series to the current time and sends its value
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	for _, state := range c.states {
+		c.collect(state, now, ch)
+	}
+	for _, family := range c.families {
+		family.churn(now)
+		for _, state := range family.states {
+			c.collect(state, now, ch)
+		}
+	}
+}
+
+// collect advances the series and sends it unless it is absent at that time
+func (c *Collector) collect(state *seriesState, now time.Time, ch chan<- prometheus.Metric) {
+	c.advance(state, now)
+	if state.absentAt(now.Sub(c.start)) {
+		return
+	}
+	ch <- state.metric(now)
+}
+
+// advance applies every interval and reset elapsed since the last update of the series
+func (c *Collector) advance(state *seriesState, now time.Time) {
+	for !state.lastTick.Add(c.interval).After(now) {
+		state.lastTick = state.lastTick.Add(c.interval)
+		state.applyResets(state.lastTick)
+		state.observe(state.valueAt(state.lastTick), state.lastTick)
+	}
+	state.applyResets(now)
+}
+
+func (s *seriesState) valueAt(t time.Time) float64 {
+	return s.generator.Value(t.Sub(s.start))
+}
+
+func (s *seriesState) observe(value float64, t time.Time) {
+	switch s.series.Type {
+	case TypeCounter:
+		s.counter += value
+		if s.series.Exemplars {
+			s.exemplars[0] = newExemplar(value, t)
+		}
+	case TypeHistogram:
+		s.count++
+		s.sum += value
+		bucket := len(s.series.Buckets)
+		for i, upperBound := range s.series.Buckets {
+			if value <= upperBound {
+				s.bucketCounts[i]++
+				bucket = i
+				break
+			}
+		}
+		if s.series.Exemplars {
+			s.exemplars[bucket] = newExemplar(value, t)
+		}
+		if s.exponential != nil {
+			s.exponential.observe(value)
+		}
+	case TypeSummary:
+		s.count++
+		s.sum += value
+		s.observations = append(s.observations, value)
+		if len(s.observations) > summaryWindow {
+			s.observations = s.observations[len(s.observations)-summaryWindow:]
+		}
+	}
+}
+
+func (s *seriesState) metric(now time.Time) prometheus.Metric {
+	help := s.series.Help
+	if help == "" {
+		help = fmt.Sprintf("A custom %s metric", s.series.Type)
+	}
+	desc := prometheus.NewDesc(s.series.Name, help, nil, s.series.Labels)
+
+	switch s.series.Type {
+	case TypeCounter:
+		counter := prometheus.MustNewConstMetricWithCreatedTimestamp(desc, prometheus.CounterValue, s.counter, s.created)
+		return s.withExemplars(counter)
+	case TypeHistogram:
+		buckets := make(map[float64]uint64, len(s.series.Buckets))
+		var cumulative uint64
+		for i, upperBound := range s.series.Buckets {
+			cumulative += s.bucketCounts[i]
+			buckets[upperBound] = cumulative
+		}
+		histogram := prometheus.MustNewConstHistogramWithCreatedTimestamp(desc, s.count, s.sum, buckets, s.created)
+		if s.exponential != nil {
+			histogram = newExponentialHistogram(histogram, s.exponential)
+		}
+		return s.withExemplars(histogram)
+	case TypeSummary:
+		return prometheus.MustNewConstSummaryWithCreatedTimestamp(desc, s.count, s.sum, s.quantiles(), s.created)
+	case TypeUntyped:
+		return prometheus.MustNewConstMetric(desc, prometheus.UntypedValue, s.valueAt(now))
+	default:
+		return prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, s.valueAt(now))
+	}
+}
+
+// withExemplars attaches the recorded exemplars to the metric, if any
+func (s *seriesState) withExemplars(metric prometheus.Metric) prometheus.Metric {
+	var exemplars []prometheus.Exemplar
+	for _, exemplar := range s.exemplars {
+		if exemplar != nil {
+			exemplars = append(exemplars, *exemplar)
+		}
+	}
+	if len(exemplars) == 0 {
+		return metric
+	}
+	return prometheus.MustNewMetricWithExemplars(metric, exemplars...)
+}
+
+// quantiles computes the configured quantiles over the retained observations
+func (s *seriesState) quantiles() map[float64]float64 {
+	quantiles := make(map[float64]float64, len(s.series.Quantiles))
+	if len(s.observations) == 0 {
+		for _, quantile := range s.series.Quantiles {
+			quantiles[quantile] = math.NaN()
+		}
+		return quantiles
+	}
+
+	sorted := slices.Clone(s.observations)
+	slices.Sort(sorted)
+	for _, quantile := range s.series.Quantiles {
+		index := int(math.Ceil(quantile*float64(len(sorted)))) - 1
+		quantiles[quantile] = sorted[max(index, 0)]
+	}
+	return quantiles
+}
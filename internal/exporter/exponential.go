@@ -0,0 +1,123 @@
+package exporter
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// ExponentialScale of exponential histograms, each bucket is 2^(2^-3), about
+// 9%, wider than the previous one
+const ExponentialScale = 3
+
+// exponentialBuckets counts observations in buckets of exponentially growing
+// width, bucket i holding the values within (base^(i-1), base^i]
+type exponentialBuckets struct {
+	zeroCount uint64
+	positive  map[int]uint64
+	negative  map[int]uint64
+}
+
+func newExponentialBuckets() *exponentialBuckets {
+	return &exponentialBuckets{positive: make(map[int]uint64), negative: make(map[int]uint64)}
+}
+
+// observe counts a value in its bucket. NaN and infinities have none, they are
+// only counted in the count and sum of the histogram as client_golang does
+func (b *exponentialBuckets) observe(value float64) {
+	switch {
+	case math.IsNaN(value) || math.IsInf(value, 0):
+	case math.Abs(value) <= prometheus.DefNativeHistogramZeroThreshold:
+		b.zeroCount++
+	case value > 0:
+		b.positive[exponentialIndex(value)]++
+	default:
+		b.negative[exponentialIndex(-value)]++
+	}
+}
+
+func (b *exponentialBuckets) reset() {
+	b.zeroCount = 0
+	clear(b.positive)
+	clear(b.negative)
+}
+
+// exponentialIndex returns the index of the bucket holding a positive value
+func exponentialIndex(value float64) int {
+	return int(math.Ceil(math.Log2(value) * math.Exp2(ExponentialScale)))
+}
+
+func validateExponential(series *Series) error {
+	if series.Exponential && series.Type != TypeHistogram {
+		return fmt.Errorf("exponential buckets are only supported on histograms, metric %s is a %s", series.Name, series.Type)
+	}
+	return nil
+}
+
+// exponentialHistogram is a histogram exposing exponential buckets, as a
+// native histogram, along with its classic buckets
+type exponentialHistogram struct {
+	prometheus.Metric
+	zeroCount     uint64
+	positiveSpans []*dto.BucketSpan
+	positiveDelta []int64
+	negativeSpans []*dto.BucketSpan
+	negativeDelta []int64
+}
+
+func newExponentialHistogram(classic prometheus.Metric, buckets *exponentialBuckets) prometheus.Metric {
+	histogram := &exponentialHistogram{Metric: classic, zeroCount: buckets.zeroCount}
+	histogram.positiveSpans, histogram.positiveDelta = bucketSpans(buckets.positive)
+	histogram.negativeSpans, histogram.negativeDelta = bucketSpans(buckets.negative)
+	return histogram
+}
+
+func (h *exponentialHistogram) Write(out *dto.Metric) error {
+	if err := h.Metric.Write(out); err != nil {
+		return err
+	}
+	histogram := out.GetHistogram()
+	histogram.Schema = proto.Int32(ExponentialScale)
+	histogram.ZeroThreshold = proto.Float64(prometheus.DefNativeHistogramZeroThreshold)
+	histogram.ZeroCount = proto.Uint64(h.zeroCount)
+	histogram.PositiveSpan = h.positiveSpans
+	histogram.PositiveDelta = h.positiveDelta
+	histogram.NegativeSpan = h.negativeSpans
+	histogram.NegativeDelta = h.negativeDelta
+	return nil
+}
+
+// bucketSpans encodes bucket counts as spans of consecutive buckets, the first
+// span offset being the first index and the next ones the gap since the
+// previous span, and counts as deltas from the previous bucket
+func bucketSpans(buckets map[int]uint64) ([]*dto.BucketSpan, []int64) {
+	indexes := make([]int, 0, len(buckets))
+	for index, count := range buckets {
+		if count > 0 {
+			indexes = append(indexes, index)
+		}
+	}
+	slices.Sort(indexes)
+
+	var spans []*dto.BucketSpan
+	var deltas []int64
+	var previousCount int64
+	for i, index := range indexes {
+		switch {
+		case i == 0:
+			spans = append(spans, &dto.BucketSpan{Offset: proto.Int32(int32(index)), Length: proto.Uint32(1)})
+		case index == indexes[i-1]+1:
+			*spans[len(spans)-1].Length++
+		default:
+			spans = append(spans, &dto.BucketSpan{Offset: proto.Int32(int32(index - indexes[i-1] - 1)), Length: proto.Uint32(1)})
+		}
+		count := int64(buckets[index])
+		deltas = append(deltas, count-previousCount)
+		previousCount = count
+	}
+	return spans, deltas
+}
@@ -0,0 +1,216 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/generator"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultListenAddress is the address the exporter listens on inside the metric pod
+const DefaultListenAddress = ":8080"
+
+// Series describes a metric family served by the exporter, one series is
+// emitted per label set
+type Series struct {
+	Name   string            `json:"name"`
+	Help   string            `json:"help,omitempty"`
+	Type   string            `json:"type,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// LabelSets emits one series per label set, each merged with Labels
+	LabelSets []map[string]string `json:"labelSets,omitempty"`
+	// LabelValues emits one series per combination of the values of every label
+	LabelValues map[string][]string `json:"labelValues,omitempty"`
+	Value       Float               `json:"value"`
+	Buckets     []float64           `json:"buckets,omitempty"`
+	Quantiles   []float64           `json:"quantiles,omitempty"`
+	// Unit of the metric such as seconds or bytes, appended to the name when it does not end with it
+	Unit string `json:"unit,omitempty"`
+	// Generator makes the value vary over time around Value, the value is constant when nil
+	Generator *generator.Spec `json:"generator,omitempty"`
+	// Cardinality generates many series differing by a high cardinality label
+	Cardinality *Cardinality `json:"cardinality,omitempty"`
+	// ResetAt lists times in seconds after the start at which counters, histograms and summaries are reset
+	ResetAt []float64 `json:"resetAt,omitempty"`
+	// ResetEvery resets counters, histograms and summaries with this period in seconds
+	ResetEvery float64 `json:"resetEvery,omitempty"`
+	// Absent windows during which the series disappears, it reappears once a window ends
+	Absent []Window `json:"absent,omitempty"`
+	// Exemplars attaches an exemplar with a random trace ID to the samples of counters and histograms
+	Exemplars bool `json:"exemplars,omitempty"`
+	// Exponential adds exponential buckets to histograms, exposed as a native histogram
+	Exponential bool `json:"exponential,omitempty"`
+}
+
+// Float is a float64 which encodes NaN and infinities as JSON strings, plain
+// JSON numbers cannot represent them
+type Float float64
+
+// MarshalJSON encodes non finite values as "NaN", "+Inf" and "-Inf"
+func (f Float) MarshalJSON() ([]byte, error) {
+	value := float64(f)
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return json.Marshal(strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	return json.Marshal(value)
+}
+
+// UnmarshalJSON accepts JSON numbers as well as strings such as "NaN", "+Inf" or "1e3"
+func (f *Float) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("invalid metric value %q: %w", text, err)
+		}
+		*f = Float(value)
+		return nil
+	}
+
+	var value float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*f = Float(value)
+	return nil
+}
+
+// Config is the set of series served by the exporter
+type Config struct {
+	Series []Series `json:"series"`
+	// Interval in seconds between two counter increments or histogram and summary observations, defaults to 1
+	Interval float64 `json:"interval,omitempty"`
+	// Faults injected on the /metrics endpoint
+	Faults *Faults `json:"faults,omitempty"`
+}
+
+// ParseConfig decodes a JSON exporter configuration
+func ParseConfig(data []byte) (*Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error unmarshalling exporter config: %w", err)
+	}
+	return &config, nil
+}
+
+// ReadConfig reads a JSON exporter configuration from a file
+func ReadConfig(filePath string) (*Config, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading exporter config: %w", err)
+	}
+	return ParseConfig(data)
+}
+
+// Validate checks every series of the configuration without starting an exporter
+func (c Config) Validate() error {
+	if c.Faults != nil {
+		if err := c.Faults.validate(); err != nil {
+			return fmt.Errorf("invalid faults: %w", err)
+		}
+	}
+	_, err := NewCollector(c)
+	return err
+}
+
+// ExpandedSeries returns the series served for the configuration, one per label
+// set, with their defaults applied and their unit appended to their name. Series
+// of a high cardinality metric are returned once, without the high cardinality label
+func (c Config) ExpandedSeries() ([]Series, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	var expanded []Series
+	for _, configured := range c.Series {
+		for _, series := range expandSeries(configured) {
+			if err := validateSeries(&series); err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, series)
+		}
+	}
+	return expanded, nil
+}
+
+// Encode returns the JSON form of the configuration, as passed to serve-metrics
+func (c Config) Encode() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling exporter config: %w", err)
+	}
+	return string(data), nil
+}
+
+// NewHandler returns an http.Handler serving the configured series on /metrics,
+// in the OpenMetrics format when the scraper accepts it, and a /healthz
+// endpoint which is never affected by faults
+func NewHandler(config Config) (http.Handler, error) {
+	return newHandler(config, time.Now)
+}
+
+func newHandler(config Config, now func() time.Time) (http.Handler, error) {
+	if config.Faults != nil {
+		if err := config.Faults.validate(); err != nil {
+			return nil, fmt.Errorf("invalid faults: %w", err)
+		}
+	}
+	collector, err := newCollector(config, now)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collector); err != nil {
+		return nil, fmt.Errorf("error registering collector: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	var metricsHandler http.Handler = newExpositionHandler(registry, collector.units)
+	if config.Faults != nil {
+		metricsHandler = newFaultHandler(*config.Faults, metricsHandler, now)
+	}
+	mux.Handle("/metrics", metricsHandler)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux, nil
+}
+
+// Serve runs the exporter on the given address until the context is cancelled
+func Serve(ctx context.Context, address string, config Config) error {
+	handler, err := NewHandler(config)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
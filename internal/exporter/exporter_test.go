@@ -0,0 +1,460 @@
+package exporter
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/generator"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func scrape(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", recorder.Code)
+	}
+	body, _ := io.ReadAll(recorder.Body)
+	return string(body)
+}
+
+func TestHandlerServesConfiguredSeries(t *testing.T) {
+	handler, err := NewHandler(Config{Series: []Series{
+		{Name: "test_metric", Help: "A test metric", Labels: map[string]string{"message": "file"}, Value: 4},
+		{Name: "other_metric", Value: 2},
+	}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	body := scrape(t, handler)
+	for _, expected := range []string{
+		"# HELP test_metric A test metric",
+		"# TYPE test_metric gauge",
+		`test_metric{message="file"} 4`,
+		"# HELP other_metric A custom gauge metric",
+		"other_metric 2",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in scrape output, got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestNewCollectorRejectsInvalidNames(t *testing.T) {
+	if _, err := NewCollector(Config{Series: []Series{{Name: "application.part.proctime"}}}); err == nil {
+		t.Errorf("expected error for dotted metric name, got nil")
+	}
+	if _, err := NewCollector(Config{Series: []Series{{Name: "ok", Labels: map[string]string{"bad-label": "x"}}}}); err == nil {
+		t.Errorf("expected error for invalid label name, got nil")
+	}
+}
+
+func TestConfigRoundTrip(t *testing.T) {
+	config := Config{Series: []Series{{Name: "test_metric", Labels: map[string]string{"a": "it's \"quoted\""}, Value: 1.5}}}
+	encoded, err := config.Encode()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	decoded, err := ParseConfig([]byte(encoded))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if decoded.Series[0].Labels["a"] != config.Series[0].Labels["a"] || decoded.Series[0].Value != 1.5 {
+		t.Errorf("expected %+v, got %+v", config, decoded)
+	}
+}
+
+func TestCollectorMetricTypes(t *testing.T) {
+	start := time.Unix(0, 0)
+	now := start
+	collector, err := newCollector(Config{Series: []Series{
+		{Name: "requests_total", Type: TypeCounter, Value: 2},
+		{Name: "latency_seconds", Type: TypeHistogram, Value: 0.3, Buckets: []float64{0.1, 0.5, 1}},
+		{Name: "size_bytes", Type: TypeSummary, Value: 512},
+	}}, func() time.Time { return now })
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+
+	now = start.Add(3 * time.Second)
+	body := scrape(t, handler)
+	for _, expected := range []string{
+		"# TYPE requests_total counter",
+		"requests_total 6",
+		"# TYPE latency_seconds histogram",
+		`latency_seconds_bucket{le="0.1"} 0`,
+		`latency_seconds_bucket{le="0.5"} 3`,
+		`latency_seconds_bucket{le="+Inf"} 3`,
+		"latency_seconds_count 3",
+		"# TYPE size_bytes summary",
+		`size_bytes{quantile="0.99"} 512`,
+		"size_bytes_sum 1536",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in scrape output, got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestValidateRejectsUnsupportedType(t *testing.T) {
+	tests := map[string]Series{
+		"unsupported type":      {Name: "proctime", Type: "Timer"},
+		"out of range quantile": {Name: "proctime", Type: TypeSummary, Quantiles: []float64{1.5}},
+		"negative counter":      {Name: "jobs_total", Type: TypeCounter, Value: -1},
+		"NaN counter":           {Name: "jobs_total", Type: TypeCounter, Value: Float(math.NaN())},
+		"decreasing counter":    {Name: "jobs_total", Type: TypeCounter, Value: 1, Generator: &generator.Spec{Kind: generator.KindSine, Amplitude: 2, Period: 60}},
+		"NaN bucket":            {Name: "proctime", Type: TypeHistogram, Buckets: []float64{0.1, math.NaN(), 1}},
+	}
+	for name, series := range tests {
+		err := (Config{Series: []Series{series}}).Validate()
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		} else if !strings.Contains(err.Error(), series.Name) {
+			t.Errorf("%s: expected the error to name metric %s, got %v", name, series.Name, err)
+		}
+	}
+
+	counter := Series{Name: "jobs_total", Type: TypeCounter, Value: 1, Generator: &generator.Spec{Kind: generator.KindRamp, Slope: 1}}
+	if err := (Config{Series: []Series{counter}}).Validate(); err != nil {
+		t.Errorf("expected an increasing counter to be valid, got %v", err)
+	}
+}
+
+func TestFloatValuesSurviveEncoding(t *testing.T) {
+	config := Config{Series: []Series{
+		{Name: "nan_metric", Value: Float(math.NaN())},
+		{Name: "inf_metric", Value: Float(math.Inf(1))},
+		{Name: "large_metric", Value: 40000},
+		{Name: "ratio_metric", Value: 0.95},
+	}}
+	encoded, err := config.Encode()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	decoded, err := ParseConfig([]byte(encoded))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	handler, err := NewHandler(*decoded)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	body := scrape(t, handler)
+	for _, expected := range []string{"nan_metric NaN", "inf_metric +Inf", "large_metric 40000", "ratio_metric 0.95"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in scrape output, got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestLabelSetsAndCartesianProduct(t *testing.T) {
+	handler, err := NewHandler(Config{Series: []Series{
+		{
+			Name:        "http_requests",
+			Labels:      map[string]string{"service": "checkout"},
+			LabelValues: map[string][]string{"method": {"GET", "POST"}, "status_code": {"200", "500"}},
+			Value:       1,
+		},
+		{
+			Name:      "queue_size",
+			LabelSets: []map[string]string{{"queue": "orders"}, {"queue": "payments"}},
+			Value:     7,
+		},
+	}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	body := scrape(t, handler)
+	for _, expected := range []string{
+		`http_requests{method="GET",service="checkout",status_code="200"} 1`,
+		`http_requests{method="GET",service="checkout",status_code="500"} 1`,
+		`http_requests{method="POST",service="checkout",status_code="200"} 1`,
+		`http_requests{method="POST",service="checkout",status_code="500"} 1`,
+		`queue_size{queue="orders"} 7`,
+		`queue_size{queue="payments"} 7`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in scrape output, got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestValidateRejectsDuplicateSeries(t *testing.T) {
+	err := (Config{Series: []Series{{
+		Name:      "queue_size",
+		LabelSets: []map[string]string{{"queue": "orders"}, {"queue": "orders"}},
+	}}}).Validate()
+	if err == nil {
+		t.Errorf("expected error for duplicate label sets, got nil")
+	}
+}
+
+func TestCardinalityChurn(t *testing.T) {
+	start := time.Unix(0, 0)
+	now := start
+	collector, err := newCollector(Config{Series: []Series{{
+		Name:        "sessions",
+		Labels:      map[string]string{"app": "dummy"},
+		Value:       1,
+		Cardinality: &Cardinality{Series: 10, ChurnInterval: 60, ChurnRate: 0.2},
+	}}}, func() time.Time { return now })
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	ids := func() map[string]bool {
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		found := make(map[string]bool)
+		for _, metric := range families[0].GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "id" {
+					found[label.GetValue()] = true
+				}
+			}
+		}
+		return found
+	}
+
+	initial := ids()
+	if len(initial) != 10 || !initial["0"] || !initial["9"] {
+		t.Fatalf("expected ids 0 to 9, got %v", initial)
+	}
+
+	now = start.Add(2 * time.Minute)
+	churned := ids()
+	if len(churned) != 10 || churned["0"] || churned["3"] || !churned["4"] || !churned["13"] {
+		t.Errorf("expected ids 4 to 13 after two churns, got %v", churned)
+	}
+}
+
+func TestValidateRejectsInvalidCardinality(t *testing.T) {
+	err := (Config{Series: []Series{{Name: "sessions", Cardinality: &Cardinality{Series: 10, ChurnRate: 2}}}}).Validate()
+	if err == nil {
+		t.Errorf("expected error for churn rate above 1, got nil")
+	}
+	err = (Config{Series: []Series{{Name: "sessions", Labels: map[string]string{"id": "x"}, Cardinality: &Cardinality{Series: 10}}}}).Validate()
+	if err == nil {
+		t.Errorf("expected error for cardinality label already set, got nil")
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	start := time.Unix(0, 0)
+	now := start
+	series := []Series{{Name: "test_metric", Value: 4}}
+	request := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	handler, err := newHandler(Config{Series: series, Faults: &Faults{ErrorRate: 1, ErrorStatus: 503}}, func() time.Time { return now })
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if code := request(handler, "/metrics").Code; code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", code)
+	}
+	if code := request(handler, "/healthz").Code; code != http.StatusOK {
+		t.Errorf("expected healthz to be unaffected by faults, got %d", code)
+	}
+
+	handler, err = newHandler(Config{Series: series, Faults: &Faults{MalformedRate: 1}}, func() time.Time { return now })
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if body := request(handler, "/metrics").Body.String(); !strings.HasPrefix(body, "# HELP test_metric") || !strings.HasSuffix(body, malformedLine) {
+		t.Errorf("expected the exposition followed by a malformed line, got:\n%s", body)
+	}
+
+	handler, err = newHandler(Config{Series: series, Faults: &Faults{Downtime: []Window{{Start: 60, Duration: 30, Every: 300}}}}, func() time.Time { return now })
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, step := range []struct {
+		elapsed  time.Duration
+		expected int
+	}{
+		{10 * time.Second, http.StatusOK},
+		{70 * time.Second, http.StatusServiceUnavailable},
+		{100 * time.Second, http.StatusOK},
+		{370 * time.Second, http.StatusServiceUnavailable},
+	} {
+		now = start.Add(step.elapsed)
+		if code := request(handler, "/metrics").Code; code != step.expected {
+			t.Errorf("expected status %d after %s, got %d", step.expected, step.elapsed, code)
+		}
+	}
+}
+
+func TestValidateRejectsInvalidFaults(t *testing.T) {
+	err := (Config{Series: []Series{{Name: "test_metric"}}, Faults: &Faults{ErrorRate: 1.5}}).Validate()
+	if err == nil {
+		t.Errorf("expected error for error rate above 1, got nil")
+	}
+}
+
+func TestCounterResetAndAbsentWindows(t *testing.T) {
+	start := time.Unix(0, 0)
+	now := start
+	handler, err := newHandler(Config{Series: []Series{
+		{Name: "jobs_total", Type: TypeCounter, Value: 1, ResetEvery: 60},
+		{Name: "flaky_gauge", Value: 3, Absent: []Window{{Start: 30, Duration: 20}}},
+	}}, func() time.Time { return now })
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	now = start.Add(40 * time.Second)
+	body := scrape(t, handler)
+	if !strings.Contains(body, "jobs_total 40") || strings.Contains(body, "flaky_gauge") {
+		t.Errorf("expected counter at 40 and the gauge absent, got:\n%s", body)
+	}
+
+	now = start.Add(70 * time.Second)
+	body = scrape(t, handler)
+	if !strings.Contains(body, "jobs_total 11") || !strings.Contains(body, "flaky_gauge 3") {
+		t.Errorf("expected counter reset to 11 and the gauge back, got:\n%s", body)
+	}
+}
+
+func TestOpenMetricsExposition(t *testing.T) {
+	start := time.Unix(1000, 0)
+	now := start
+	handler, err := newHandler(Config{Series: []Series{
+		{Name: "jobs_total", Type: TypeCounter, Value: 1, Exemplars: true},
+		{Name: "request_duration", Type: TypeHistogram, Unit: "seconds", Value: 0.2, Buckets: []float64{0.1, 0.5}, Exemplars: true},
+	}}, func() time.Time { return now })
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	now = start.Add(3 * time.Second)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	request.Header.Set("Accept", "application/openmetrics-text;version=1.0.0")
+	handler.ServeHTTP(recorder, request)
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/openmetrics-text") {
+		t.Errorf("expected OpenMetrics content type, got %s", contentType)
+	}
+
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		"# UNIT request_duration_seconds seconds",
+		"jobs_total 3.0 # {trace_id=\"",
+		"jobs_created 1000.0",
+		"request_duration_seconds_bucket{le=\"0.5\"} 3 # {trace_id=\"",
+		"request_duration_seconds_created 1000.0",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %q in exposition, got:\n%s", expected, body)
+		}
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("expected exposition to end with # EOF, got:\n%s", body)
+	}
+
+	// The text format keeps the plain samples
+	body = scrape(t, handler)
+	if !strings.Contains(body, "request_duration_seconds_count 3") || strings.Contains(body, "# EOF") {
+		t.Errorf("expected text exposition, got:\n%s", body)
+	}
+}
+
+func TestInvalidExemplarsAndUnits(t *testing.T) {
+	tests := map[string]Series{
+		"exemplars on gauge": {Name: "test_metric", Exemplars: true},
+		"invalid unit":       {Name: "test_metric", Unit: "per-second"},
+	}
+	for name, series := range tests {
+		if err := (Config{Series: []Series{series}}).Validate(); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestExponentialHistogram(t *testing.T) {
+	start := time.Unix(0, 0)
+	now := start
+	collector, err := newCollector(Config{Series: []Series{
+		{Name: "request_duration_seconds", Type: TypeHistogram, Value: 3, Exponential: true},
+	}}, func() time.Time { return now })
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	now = start.Add(4 * time.Second)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	histogram := families[0].GetMetric()[0].GetHistogram()
+	// 3 falls in (2^(12/8), 2^(13/8)], the bucket of index 13 at scale 3
+	spans := histogram.GetPositiveSpan()
+	if histogram.GetSchema() != ExponentialScale || len(spans) != 1 || spans[0].GetOffset() != 13 || histogram.GetPositiveDelta()[0] != 4 {
+		t.Errorf("unexpected exponential buckets %v", histogram)
+	}
+
+	if err := (Config{Series: []Series{{Name: "test_metric", Exponential: true}}}).Validate(); err == nil {
+		t.Errorf("expected error for exponential buckets on a gauge, got nil")
+	}
+}
+
+func TestExponentialHistogramNonFiniteValues(t *testing.T) {
+	for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		start := time.Unix(0, 0)
+		now := start
+		collector, err := newCollector(Config{Series: []Series{
+			{Name: "request_duration_seconds", Type: TypeHistogram, Value: Float(value), Exponential: true},
+		}}, func() time.Time { return now })
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		now = start.Add(4 * time.Second)
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(collector)
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		histogram := families[0].GetMetric()[0].GetHistogram()
+		if histogram.GetSampleCount() != 4 || len(histogram.GetPositiveSpan()) != 0 || len(histogram.GetNegativeSpan()) != 0 || histogram.GetZeroCount() != 0 {
+			t.Errorf("expected %v to be counted without exponential bucket, got %v", value, histogram)
+		}
+		if sum := histogram.GetSampleSum(); math.IsNaN(sum) != math.IsNaN(value) || (!math.IsNaN(value) && sum != value*4) {
+			t.Errorf("expected %v in the sum, got %v", value, sum)
+		}
+	}
+}
+
+func TestBucketSpans(t *testing.T) {
+	spans, deltas := bucketSpans(map[int]uint64{-2: 1, -1: 3, 2: 2})
+	if len(spans) != 2 || spans[0].GetOffset() != -2 || spans[0].GetLength() != 2 || spans[1].GetOffset() != 2 || spans[1].GetLength() != 1 {
+		t.Errorf("unexpected spans %v", spans)
+	}
+	if !slices.Equal(deltas, []int64{1, 2, -1}) {
+		t.Errorf("unexpected deltas %v", deltas)
+	}
+}
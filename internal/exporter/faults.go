@@ -0,0 +1,226 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// malformedLine is appended to the exposition to make it unparsable
+const malformedLine = "obs_pusher_malformed{label=\"unterminated 1\n"
+
+// Faults describes the failures injected on the /metrics endpoint. Rates are
+// the fraction of scrapes affected, within [0,1].
+type Faults struct {
+	// ErrorRate of scrapes answered with ErrorStatus instead of metrics
+	ErrorRate float64 `json:"errorRate,omitempty"`
+	// ErrorStatus returned on failed scrapes, defaults to 500
+	ErrorStatus int `json:"errorStatus,omitempty"`
+	// Delay in seconds before answering the delayed scrapes
+	Delay float64 `json:"delay,omitempty"`
+	// DelayRate of scrapes delayed, every scrape is delayed when unset and Delay is set
+	DelayRate float64 `json:"delayRate,omitempty"`
+	// TruncateRate of scrapes whose exposition is cut in the middle
+	TruncateRate float64 `json:"truncateRate,omitempty"`
+	// MalformedRate of scrapes whose exposition contains an unparsable line
+	MalformedRate float64 `json:"malformedRate,omitempty"`
+	// Downtime windows during which the endpoint drops connections
+	Downtime []Window `json:"downtime,omitempty"`
+}
+
+// Window is a period of time relative to the exporter start
+type Window struct {
+	// Start in seconds after the exporter started
+	Start float64 `json:"start"`
+	// Duration of the window in seconds
+	Duration float64 `json:"duration"`
+	// Every repeats the window with this period in seconds when set
+	Every float64 `json:"every,omitempty"`
+}
+
+// ParseWindow parses a window given as start:duration[:every] in seconds
+func ParseWindow(raw string) (Window, error) {
+	parts := strings.Split(raw, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Window{}, fmt.Errorf("invalid window format %q, expected start:duration[:every]", raw)
+	}
+	var bounds []float64
+	for _, part := range parts {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return Window{}, fmt.Errorf("invalid window format %q, expected start:duration[:every]", raw)
+		}
+		bounds = append(bounds, bound)
+	}
+	window := Window{Start: bounds[0], Duration: bounds[1]}
+	if len(bounds) == 3 {
+		window.Every = bounds[2]
+	}
+	return window, nil
+}
+
+func (w Window) validate() error {
+	if w.Duration <= 0 || w.Start < 0 {
+		return fmt.Errorf("windows need a positive start and duration, got %+v", w)
+	}
+	if w.Every > 0 && w.Every <= w.Duration {
+		return fmt.Errorf("window must repeat after it ends, got %+v", w)
+	}
+	return nil
+}
+
+// contains reports whether the window covers the given time after start
+func (w Window) contains(elapsed time.Duration) bool {
+	seconds := elapsed.Seconds() - w.Start
+	if seconds < 0 {
+		return false
+	}
+	if w.Every > 0 {
+		seconds = math.Mod(seconds, w.Every)
+	}
+	return seconds < w.Duration
+}
+
+func (f *Faults) validate() error {
+	for name, rate := range map[string]float64{"error": f.ErrorRate, "delay": f.DelayRate, "truncate": f.TruncateRate, "malformed": f.MalformedRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s rate must be within [0,1], got %v", name, rate)
+		}
+	}
+	if f.ErrorStatus != 0 && (f.ErrorStatus < 400 || f.ErrorStatus > 599) {
+		return fmt.Errorf("error status must be a 4xx or 5xx code, got %d", f.ErrorStatus)
+	}
+	if f.Delay < 0 {
+		return fmt.Errorf("delay must be positive, got %v", f.Delay)
+	}
+	for _, window := range f.Downtime {
+		if err := window.validate(); err != nil {
+			return fmt.Errorf("invalid downtime window: %w", err)
+		}
+	}
+	return nil
+}
+
+// faultHandler injects the configured faults in front of the metrics handler
+type faultHandler struct {
+	faults Faults
+	next   http.Handler
+	start  time.Time
+	now    func() time.Time
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+func newFaultHandler(faults Faults, next http.Handler, now func() time.Time) *faultHandler {
+	if faults.ErrorStatus == 0 {
+		faults.ErrorStatus = http.StatusInternalServerError
+	}
+	if faults.Delay > 0 && faults.DelayRate == 0 {
+		faults.DelayRate = 1
+	}
+	return &faultHandler{
+		faults: faults,
+		next:   next,
+		start:  now(),
+		now:    now,
+		random: rand.New(rand.NewSource(now().UnixNano())),
+	}
+}
+
+// roll reports whether a fault happening at the given rate hits this scrape
+func (h *faultHandler) roll(rate float64) bool {
+	if rate == 0 {
+		return false
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.random.Float64() < rate
+}
+
+func (h *faultHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	elapsed := h.now().Sub(h.start)
+	for _, window := range h.faults.Downtime {
+		if window.contains(elapsed) {
+			h.drop(w)
+			return
+		}
+	}
+
+	if h.roll(h.faults.DelayRate) {
+		select {
+		case <-time.After(time.Duration(h.faults.Delay * float64(time.Second))):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if h.roll(h.faults.ErrorRate) {
+		http.Error(w, "fault injected by obs-pusher", h.faults.ErrorStatus)
+		return
+	}
+
+	truncate := h.roll(h.faults.TruncateRate)
+	malformed := h.roll(h.faults.MalformedRate)
+	if !truncate && !malformed {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	// Rewriting the exposition needs an uncompressed body
+	r.Header.Del("Accept-Encoding")
+	buffer := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+	h.next.ServeHTTP(buffer, r)
+
+	body := buffer.body.Bytes()
+	if truncate {
+		body = body[:len(body)/2]
+	}
+	if malformed {
+		body = append(body, malformedLine...)
+	}
+	for key, values := range buffer.header {
+		w.Header()[key] = values
+	}
+	w.Header().Del("Content-Length")
+	w.WriteHeader(buffer.status)
+	w.Write(body)
+}
+
+// drop closes the connection without answering, falling back to a 503 when
+// the connection cannot be taken over
+func (h *faultHandler) drop(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if ok {
+		connection, _, err := hijacker.Hijack()
+		if err == nil {
+			connection.Close()
+			return
+		}
+	}
+	http.Error(w, "endpoint down, fault injected by obs-pusher", http.StatusServiceUnavailable)
+}
+
+// bufferedResponse records a response so it can be altered before being sent
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	return b.body.Write(data)
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
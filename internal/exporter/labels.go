@@ -0,0 +1,69 @@
+package exporter
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// labelSets returns the label set of every series described by the Series: the
+// base labels merged with each explicit label set and each combination of the
+// label values
+func (s Series) labelSets() []map[string]string {
+	combinations := []map[string]string{{}}
+	keys := slices.Sorted(maps.Keys(s.LabelValues))
+	for _, key := range keys {
+		var expanded []map[string]string
+		for _, combination := range combinations {
+			for _, value := range s.LabelValues[key] {
+				next := maps.Clone(combination)
+				next[key] = value
+				expanded = append(expanded, next)
+			}
+		}
+		combinations = expanded
+	}
+
+	sets := s.LabelSets
+	if len(sets) == 0 {
+		sets = []map[string]string{{}}
+	}
+
+	var labelSets []map[string]string
+	for _, set := range sets {
+		for _, combination := range combinations {
+			labels := maps.Clone(s.Labels)
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			maps.Copy(labels, set)
+			maps.Copy(labels, combination)
+			labelSets = append(labelSets, labels)
+		}
+	}
+	return labelSets
+}
+
+// expandSeries returns one series per label set of the given series
+func expandSeries(series Series) []Series {
+	var expanded []Series
+	for _, labels := range series.labelSets() {
+		single := series
+		single.Labels = labels
+		single.LabelSets = nil
+		single.LabelValues = nil
+		expanded = append(expanded, single)
+	}
+	return expanded
+}
+
+// seriesKey identifies a series by its name and label set
+func seriesKey(series Series) string {
+	var builder strings.Builder
+	builder.WriteString(series.Name)
+	for _, key := range slices.Sorted(maps.Keys(series.Labels)) {
+		fmt.Fprintf(&builder, ",%s=%q", key, series.Labels[key])
+	}
+	return builder.String()
+}
@@ -0,0 +1,70 @@
+package exporter
+
+import (
+	"fmt"
+	"time"
+)
+
+func validateLifecycle(series *Series) error {
+	if series.ResetEvery < 0 {
+		return fmt.Errorf("reset interval must be positive on metric %s", series.Name)
+	}
+	for _, resetAt := range series.ResetAt {
+		if resetAt <= 0 {
+			return fmt.Errorf("reset times must be positive on metric %s", series.Name)
+		}
+	}
+	for _, window := range series.Absent {
+		if err := window.validate(); err != nil {
+			return fmt.Errorf("invalid absent window on metric %s: %w", series.Name, err)
+		}
+	}
+	return nil
+}
+
+// resetDue reports whether a reset of the series is scheduled within (from, to]
+func (s *seriesState) resetDue(from, to time.Time) bool {
+	fromSeconds := from.Sub(s.start).Seconds()
+	toSeconds := to.Sub(s.start).Seconds()
+	for _, resetAt := range s.series.ResetAt {
+		if resetAt > fromSeconds && resetAt <= toSeconds {
+			return true
+		}
+	}
+	if s.series.ResetEvery > 0 {
+		return int64(toSeconds/s.series.ResetEvery) > int64(fromSeconds/s.series.ResetEvery)
+	}
+	return false
+}
+
+// reset drops everything accumulated by the series, as a restarted process would
+func (s *seriesState) reset(t time.Time) {
+	s.created = t
+	s.counter = 0
+	s.count = 0
+	s.sum = 0
+	clear(s.bucketCounts)
+	s.observations = nil
+	clear(s.exemplars)
+	if s.exponential != nil {
+		s.exponential.reset()
+	}
+}
+
+// applyResets resets the series when a reset is scheduled since the last check
+func (s *seriesState) applyResets(now time.Time) {
+	if s.resetDue(s.resetChecked, now) {
+		s.reset(now)
+	}
+	s.resetChecked = now
+}
+
+// absentAt reports whether the series is hidden at the given time after the exporter started
+func (s *seriesState) absentAt(elapsed time.Duration) bool {
+	for _, window := range s.series.Absent {
+		if window.contains(elapsed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package exporter
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// traceIDLabel is the exemplar label holding the trace ID
+const traceIDLabel = "trace_id"
+
+// newExpositionHandler serves the gathered metrics in the format negotiated
+// with the scraper. The OpenMetrics format carries the units, the _created
+// series and the exemplars and ends with # EOF.
+func newExpositionHandler(gatherer prometheus.Gatherer, units map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		families, err := gatherer.Gather()
+		if err != nil {
+			http.Error(w, "error gathering metrics: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		var buffer bytes.Buffer
+		encoder := expfmt.NewEncoder(&buffer, format, expfmt.WithCreatedLines(), expfmt.WithUnit())
+		for _, family := range families {
+			if unit, ok := units[family.GetName()]; ok {
+				family.Unit = &unit
+			}
+			if err := encoder.Encode(family); err != nil {
+				http.Error(w, "error encoding metrics: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if closer, ok := encoder.(expfmt.Closer); ok {
+			if err := closer.Close(); err != nil {
+				http.Error(w, "error encoding metrics: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", string(format))
+		w.Write(buffer.Bytes())
+	})
+}
+
+// validateUnit appends the unit to the series name when missing, before the
+// _total suffix of counters, as OpenMetrics requires
+func validateUnit(series *Series) error {
+	if series.Unit == "" {
+		return nil
+	}
+	if !model.LabelName(series.Unit).IsValid() || strings.Contains(series.Unit, "__") {
+		return fmt.Errorf("invalid unit %q on metric %s", series.Unit, series.Name)
+	}
+	name, total := series.Name, false
+	if series.Type == TypeCounter {
+		name, total = strings.CutSuffix(name, "_total")
+	}
+	if !strings.HasSuffix(name, "_"+series.Unit) {
+		name += "_" + series.Unit
+	}
+	if total {
+		name += "_total"
+	}
+	series.Name = name
+	return nil
+}
+
+func validateExemplars(series *Series) error {
+	if series.Exemplars && series.Type != TypeCounter && series.Type != TypeHistogram {
+		return fmt.Errorf("exemplars are only supported on counters and histograms, metric %s is a %s", series.Name, series.Type)
+	}
+	return nil
+}
+
+// newExemplar records an observation made at the given time with a random trace ID
+func newExemplar(value float64, t time.Time) *prometheus.Exemplar {
+	traceID := make([]byte, 16)
+	rand.Read(traceID)
+	return &prometheus.Exemplar{
+		Value:     value,
+		Labels:    prometheus.Labels{traceIDLabel: hex.EncodeToString(traceID)},
+		Timestamp: t,
+	}
+}
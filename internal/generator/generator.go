@@ -0,0 +1,250 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Generator kinds
+const (
+	KindConstant   = "constant"
+	KindRamp       = "ramp"
+	KindSine       = "sine"
+	KindRandomWalk = "random-walk"
+	KindStep       = "step"
+	KindSpike      = "spike"
+)
+
+// Spec describes how a metric value evolves over time. The base value of the
+// metric is the constant value, the ramp start, the sine offset, the random
+// walk start, the value before a step and the baseline between spikes.
+type Spec struct {
+	Kind string `json:"kind"`
+	// Slope of a ramp in units per second
+	Slope float64 `json:"slope,omitempty"`
+	// Amplitude of a sine wave
+	Amplitude float64 `json:"amplitude,omitempty"`
+	// Period in seconds of a sine wave or between spikes, a ramp restarts every period when set
+	Period float64 `json:"period,omitempty"`
+	// Min and Max bound a random walk
+	Min float64 `json:"min,omitempty"`
+	Max float64 `json:"max,omitempty"`
+	// Step is the largest change of a random walk per second
+	Step float64 `json:"step,omitempty"`
+	// At is the time in seconds at which a step happens
+	At float64 `json:"at,omitempty"`
+	// To is the value after a step or during a spike
+	To float64 `json:"to,omitempty"`
+	// Duration in seconds of a spike
+	Duration float64 `json:"duration,omitempty"`
+	// Seed of a random walk, a random seed is used when zero
+	Seed int64 `json:"seed,omitempty"`
+}
+
+// Generator returns the value of a metric a given time after it started.
+// Generators are not safe for concurrent use.
+type Generator interface {
+	Value(elapsed time.Duration) float64
+}
+
+// New creates the generator described by the spec around the base value
+func New(spec Spec, base float64) (Generator, error) {
+	switch spec.Kind {
+	case "", KindConstant:
+		return constant{value: base}, nil
+	case KindRamp:
+		if spec.Period < 0 {
+			return nil, fmt.Errorf("ramp period must be positive, got %v", spec.Period)
+		}
+		return ramp{start: base, slope: spec.Slope, period: spec.Period}, nil
+	case KindSine:
+		if spec.Period <= 0 {
+			return nil, fmt.Errorf("sine period must be positive, got %v", spec.Period)
+		}
+		return sine{offset: base, amplitude: spec.Amplitude, period: spec.Period}, nil
+	case KindRandomWalk:
+		if spec.Min >= spec.Max {
+			return nil, fmt.Errorf("random walk min %v must be lower than max %v", spec.Min, spec.Max)
+		}
+		if spec.Step <= 0 {
+			return nil, fmt.Errorf("random walk step must be positive, got %v", spec.Step)
+		}
+		seed := spec.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		return &randomWalk{
+			current: math.Min(math.Max(base, spec.Min), spec.Max),
+			step:    spec.Step,
+			min:     spec.Min,
+			max:     spec.Max,
+			random:  rand.New(rand.NewSource(seed)),
+		}, nil
+	case KindStep:
+		return step{before: base, after: spec.To, at: spec.At}, nil
+	case KindSpike:
+		if spec.Period <= 0 || spec.Duration <= 0 || spec.Duration >= spec.Period {
+			return nil, fmt.Errorf("spike needs a positive period and a duration shorter than it, got period %v and duration %v", spec.Period, spec.Duration)
+		}
+		return spike{baseline: base, peak: spec.To, period: spec.Period, duration: spec.Duration}, nil
+	default:
+		return nil, fmt.Errorf("unknown generator kind %q", spec.Kind)
+	}
+}
+
+// Min returns the lowest value generated by the spec around the base value,
+// -Inf for a decreasing ramp which never restarts
+func Min(spec Spec, base float64) float64 {
+	switch spec.Kind {
+	case KindRamp:
+		if spec.Slope >= 0 {
+			return base
+		}
+		if spec.Period == 0 {
+			return math.Inf(-1)
+		}
+		return base + spec.Slope*spec.Period
+	case KindSine:
+		return base - math.Abs(spec.Amplitude)
+	case KindRandomWalk:
+		return spec.Min
+	case KindStep, KindSpike:
+		return math.Min(base, spec.To)
+	default:
+		return base
+	}
+}
+
+// ParseSpec builds a spec from a kind and parameters given as "key=value,anotherkey=anothervalue"
+func ParseSpec(kind string, params string) (Spec, error) {
+	spec := Spec{Kind: kind}
+	if params == "" {
+		return spec, nil
+	}
+
+	for _, pair := range strings.Split(params, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return spec, fmt.Errorf("invalid generator parameter format: %s", pair)
+		}
+		key := strings.TrimSpace(kv[0])
+		if key == "seed" {
+			seed, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 64)
+			if err != nil {
+				return spec, fmt.Errorf("invalid generator seed %q: %w", kv[1], err)
+			}
+			spec.Seed = seed
+			continue
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			return spec, fmt.Errorf("invalid value for generator parameter %s: %w", key, err)
+		}
+		switch key {
+		case "slope":
+			spec.Slope = value
+		case "amplitude":
+			spec.Amplitude = value
+		case "period":
+			spec.Period = value
+		case "min":
+			spec.Min = value
+		case "max":
+			spec.Max = value
+		case "step":
+			spec.Step = value
+		case "at":
+			spec.At = value
+		case "to":
+			spec.To = value
+		case "duration":
+			spec.Duration = value
+		default:
+			return spec, fmt.Errorf("unknown generator parameter: %s", key)
+		}
+	}
+	return spec, nil
+}
+
+type constant struct {
+	value float64
+}
+
+func (g constant) Value(elapsed time.Duration) float64 {
+	return g.value
+}
+
+type ramp struct {
+	start  float64
+	slope  float64
+	period float64
+}
+
+func (g ramp) Value(elapsed time.Duration) float64 {
+	seconds := elapsed.Seconds()
+	if g.period > 0 {
+		seconds = math.Mod(seconds, g.period)
+	}
+	return g.start + g.slope*seconds
+}
+
+type sine struct {
+	offset    float64
+	amplitude float64
+	period    float64
+}
+
+func (g sine) Value(elapsed time.Duration) float64 {
+	return g.offset + g.amplitude*math.Sin(2*math.Pi*elapsed.Seconds()/g.period)
+}
+
+// randomWalk moves by at most step every elapsed second, staying within [min,max]
+type randomWalk struct {
+	current float64
+	step    float64
+	min     float64
+	max     float64
+	seconds int64
+	random  *rand.Rand
+}
+
+func (g *randomWalk) Value(elapsed time.Duration) float64 {
+	for target := int64(elapsed.Seconds()); g.seconds < target; g.seconds++ {
+		g.current += (g.random.Float64()*2 - 1) * g.step
+		g.current = math.Min(math.Max(g.current, g.min), g.max)
+	}
+	return g.current
+}
+
+type step struct {
+	before float64
+	after  float64
+	at     float64
+}
+
+func (g step) Value(elapsed time.Duration) float64 {
+	if elapsed.Seconds() >= g.at {
+		return g.after
+	}
+	return g.before
+}
+
+// spike is at its peak for duration seconds at the end of every period
+type spike struct {
+	baseline float64
+	peak     float64
+	period   float64
+	duration float64
+}
+
+func (g spike) Value(elapsed time.Duration) float64 {
+	if math.Mod(elapsed.Seconds(), g.period) >= g.period-g.duration {
+		return g.peak
+	}
+	return g.baseline
+}
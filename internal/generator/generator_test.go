@@ -0,0 +1,107 @@
+package generator
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func valueAt(t *testing.T, spec Spec, base float64, elapsed time.Duration) float64 {
+	t.Helper()
+	generator, err := New(spec, base)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return generator.Value(elapsed)
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     Spec
+		base     float64
+		elapsed  time.Duration
+		expected float64
+	}{
+		{"constant", Spec{}, 4, time.Hour, 4},
+		{"ramp", Spec{Kind: KindRamp, Slope: 2}, 10, 5 * time.Second, 20},
+		{"ramp restarts every period", Spec{Kind: KindRamp, Slope: 1, Period: 60}, 0, 70 * time.Second, 10},
+		{"sine peak", Spec{Kind: KindSine, Amplitude: 5, Period: 40}, 50, 10 * time.Second, 55},
+		{"step before", Spec{Kind: KindStep, At: 30, To: 100}, 1, 29 * time.Second, 1},
+		{"step after", Spec{Kind: KindStep, At: 30, To: 100}, 1, 30 * time.Second, 100},
+		{"spike baseline", Spec{Kind: KindSpike, Period: 60, Duration: 10, To: 99}, 1, 45 * time.Second, 1},
+		{"spike peak", Spec{Kind: KindSpike, Period: 60, Duration: 10, To: 99}, 1, 55 * time.Second, 99},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value := valueAt(t, test.spec, test.base, test.elapsed)
+			if math.Abs(value-test.expected) > 1e-9 {
+				t.Errorf("expected %v, got %v", test.expected, value)
+			}
+		})
+	}
+}
+
+func TestMin(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     Spec
+		base     float64
+		expected float64
+	}{
+		{"constant", Spec{}, 4, 4},
+		{"increasing ramp", Spec{Kind: KindRamp, Slope: 2}, 10, 10},
+		{"decreasing ramp restarting", Spec{Kind: KindRamp, Slope: -1, Period: 60}, 100, 40},
+		{"decreasing ramp", Spec{Kind: KindRamp, Slope: -1}, 100, math.Inf(-1)},
+		{"sine", Spec{Kind: KindSine, Amplitude: -5, Period: 40}, 50, 45},
+		{"random walk", Spec{Kind: KindRandomWalk, Min: 2, Max: 8, Step: 1}, 5, 2},
+		{"step down", Spec{Kind: KindStep, At: 30, To: -1}, 1, -1},
+		{"spike", Spec{Kind: KindSpike, Period: 60, Duration: 10, To: 99}, 1, 1},
+	}
+	for _, test := range tests {
+		if lowest := Min(test.spec, test.base); lowest != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, lowest)
+		}
+	}
+}
+
+func TestRandomWalkStaysWithinBounds(t *testing.T) {
+	generator, err := New(Spec{Kind: KindRandomWalk, Min: 0, Max: 10, Step: 3, Seed: 42}, 5)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	previous := generator.Value(0)
+	for second := 1; second <= 500; second++ {
+		value := generator.Value(time.Duration(second) * time.Second)
+		if value < 0 || value > 10 {
+			t.Fatalf("value %v out of bounds at %ds", value, second)
+		}
+		if math.Abs(value-previous) > 3 {
+			t.Fatalf("value moved by more than the step at %ds: %v -> %v", second, previous, value)
+		}
+		previous = value
+	}
+
+	for _, step := range []float64{0, -1} {
+		if _, err := New(Spec{Kind: KindRandomWalk, Min: 0, Max: 10, Step: step}, 5); err == nil {
+			t.Errorf("expected error for step %v, got nil", step)
+		}
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec(KindSpike, "period=60,duration=5,to=1e3")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if spec.Period != 60 || spec.Duration != 5 || spec.To != 1000 {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+
+	if _, err := ParseSpec(KindSine, "wavelength=3"); err == nil {
+		t.Errorf("expected error for unknown parameter, got nil")
+	}
+	if _, err := New(Spec{Kind: "sawtooth"}, 0); err == nil {
+		t.Errorf("expected error for unknown kind, got nil")
+	}
+}
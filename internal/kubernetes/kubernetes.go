@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
@@ -22,22 +23,43 @@ import (
 // KubernetesClient is an interface for mocking purposes
 type KubernetesClient interface {
 	CreateNamespace(name string) error
-	CreateMetricPod(namespace, name string, imageArgs []string, labels map[string]string) error
-	CreateLogPod(namespace, name string, imageArgs []string, labels map[string]string) error
+	CreateMetricPod(namespace, name string, imageArgs []string, labels, annotations map[string]string, isClusterRestricted bool) error
+	CreateLogPod(namespace, name string, imageArgs []string, labels map[string]string, sequenceConfigMap string, env []corev1.EnvVar, isClusterRestricted bool) error
+	CreateConfigMap(namespace, name string, labels, data map[string]string) error
+	CreateSecret(namespace, name string, labels, data map[string]string) error
 	CreateService(namespace, name string, serviceType, labels map[string]string) error
-	CreateServiceMonitor(namespace, name string, labels map[string]string) error
+	CreateServiceMonitor(namespace, name string, labels map[string]string, settings ScrapeSettings) error
+	CreatePodMonitor(namespace, name string, labels map[string]string, settings ScrapeSettings) error
+	CreatePrometheusRule(namespace, name string, labels map[string]string, rules []v1.Rule) error
 	IsNamespaceExisting(namespace string) (bool, error)
 	IsPodExisting(name, namespace string) (bool, error)
 	IsServiceExisting(name, namespace string) (bool, error)
 	IsServiceMonitorExisting(name, namespace string) (bool, error)
+	IsPodMonitorExisting(name, namespace string) (bool, error)
 	FetchPodByLabels(namespace string, labels map[string]string) (*corev1.PodList, error)
 	FetchServiceByLabels(namespace string, labels map[string]string) (*corev1.ServiceList, error)
 	FetchServiceMonitorByLabels(namespace string, labels map[string]string) (*v1.ServiceMonitorList, error)
+	FetchPodMonitorByLabels(namespace string, labels map[string]string) (*v1.PodMonitorList, error)
+	FetchPrometheusRuleByLabels(namespace string, labels map[string]string) (*v1.PrometheusRuleList, error)
+	FetchConfigMapByLabels(namespace string, labels map[string]string) (*corev1.ConfigMapList, error)
+	FetchSecretByLabels(namespace string, labels map[string]string) (*corev1.SecretList, error)
 	DeletePod(name, namespace string) error
 	DeleteService(name, namespace string) error
 	DeleteServiceMonitor(name, namespace string) error
+	DeletePodMonitor(name, namespace string) error
+	DeletePrometheusRule(name, namespace string) error
+	DeleteConfigMap(name, namespace string) error
+	DeleteSecret(name, namespace string) error
 }
 
+const (
+	obsPusherImage  = "ghcr.io/patrick-ivann/obs-pusher:latest"
+	metricsPortName = "http-metrics"
+	metricsPort     = 8080
+	// LogSequencePath is where the log sequence ConfigMap of a log pod is mounted
+	LogSequencePath = "/etc/obs-pusher/log-sequence"
+)
+
 // Client implements the KubernetesClient interface
 type Client struct {
 	clientset           *kubernetes.Clientset
@@ -129,104 +151,60 @@ func (c *Client) CreateNamespace(name string) error {
 	return err
 }
 
-// CreatePod creates a pod
-func (c *Client) CreateMetricPod(namespace, name string, imageArgs []string, labels map[string]string, isClusterRestricted bool) error {
+// ScrapeAnnotations returns the prometheus.io annotations the classic
+// kubernetes-pods scrape configuration discovers metric pods with
+func ScrapeAnnotations() map[string]string {
+	return map[string]string{
+		"prometheus.io/scrape": "true",
+		"prometheus.io/port":   strconv.Itoa(metricsPort),
+		"prometheus.io/path":   "/metrics",
+	}
+}
 
-	image := "alpine"
+// CreateMetricPod creates a pod running the obs-pusher exporter with the given serve-metrics arguments
+func (c *Client) CreateMetricPod(namespace, name string, imageArgs []string, labels, annotations map[string]string, isClusterRestricted bool) error {
 
+	image := obsPusherImage
 	if c.registryPath != "" {
-		image = fmt.Sprintf("%s/alpine", c.registryPath)
+		image = fmt.Sprintf("%s/obs-pusher", c.registryPath)
 	}
 
 	unprivileged := false
 	readOnly := true
+	probeHandler := corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path: "/healthz",
+			Port: intstr.FromString(metricsPortName),
+		},
+	}
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Labels:    labels,
-			Name:      name,
-			Namespace: namespace,
+			Labels:      labels,
+			Annotations: annotations,
+			Name:        name,
+			Namespace:   namespace,
 		},
 		Spec: corev1.PodSpec{
-			Volumes: []corev1.Volume{
-				{
-					Name: "metrics",
-					VolumeSource: corev1.VolumeSource{
-						EmptyDir: &corev1.EmptyDirVolumeSource{},
-					},
-				},
-			},
 			Containers: []corev1.Container{
 				{
-					Name:  name + "-" + "generates",
+					Name:  name + "-" + "exporter",
 					Image: image,
 					Args:  imageArgs,
 					SecurityContext: &corev1.SecurityContext{
 						Capabilities: &corev1.Capabilities{
 							Drop: []corev1.Capability{"ALL"},
 						},
-						Privileged:             &unprivileged,
-						ReadOnlyRootFilesystem: &readOnly,
-					},
-					VolumeMounts: []corev1.VolumeMount{
-						{
-							Name:      "metrics",
-							MountPath: "/usr/share/nginx/html",
-						},
-					},
-				},
-				{
-					Name:    name + "-" + "exposing",
-					Image:   "nginx:alpine",
-					Command: []string{"/bin/sh", "-c"},
-					Args: []string{` # Configure Nginx
-          echo 'server {
-              listen 80;
-              location /metrics {
-                  default_type text/plain;
-                  add_header Content-Type "text/plain; version=0.0.4; charset=utf-8";
-                  root /usr/share/nginx/html;
-              }
-          }' > /etc/nginx/conf.d/default.conf;
-          # Start Nginx
-          nginx -g 'daemon off;'`,
+						Privileged:               &unprivileged,
+						AllowPrivilegeEscalation: &unprivileged,
+						ReadOnlyRootFilesystem:   &readOnly,
 					},
 					Ports: []corev1.ContainerPort{{
-						ContainerPort: 80,
+						Name:          metricsPortName,
+						ContainerPort: metricsPort,
+						Protocol:      corev1.ProtocolTCP,
 					}},
-					ReadinessProbe: &corev1.Probe{
-						ProbeHandler: corev1.ProbeHandler{
-							HTTPGet: &corev1.HTTPGetAction{
-								Path: "/metrics",
-								Port: intstr.FromInt(80),
-								HTTPHeaders: []corev1.HTTPHeader{
-									{
-										Name:  "Accept",
-										Value: "text/plain; version=0.0.4; charset=utf-8",
-									},
-								},
-							},
-						},
-					},
-					LivenessProbe: &corev1.Probe{
-						ProbeHandler: corev1.ProbeHandler{
-							HTTPGet: &corev1.HTTPGetAction{
-								Path: "/metrics",
-								Port: intstr.FromInt(80),
-								HTTPHeaders: []corev1.HTTPHeader{
-									{
-										Name:  "Accept",
-										Value: "text/plain; version=0.0.4; charset=utf-8",
-									},
-								},
-							},
-						},
-					},
-					VolumeMounts: []corev1.VolumeMount{
-						{
-							Name:      "metrics",
-							MountPath: "/usr/share/nginx/html",
-						},
-					},
+					ReadinessProbe: &corev1.Probe{ProbeHandler: probeHandler},
+					LivenessProbe:  &corev1.Probe{ProbeHandler: probeHandler},
 				},
 			},
 		},
@@ -248,13 +226,19 @@ func (c *Client) CreateMetricPod(namespace, name string, imageArgs []string, lab
 	return err
 }
 
-func (c *Client) CreateLogPod(namespace, name string, imageArgs []string, labels map[string]string, isClusterRestricted bool) error {
+// CreateLogPod creates a pod running obs-pusher with the given emit-logs
+// arguments and environment, the sequenceConfigMap being mounted on
+// LogSequencePath when set.
+// The pod is not restarted so its phase tells whether the sequence completed
+func (c *Client) CreateLogPod(namespace, name string, imageArgs []string, labels map[string]string, sequenceConfigMap string, env []corev1.EnvVar, isClusterRestricted bool) error {
 
-	image := "alpine"
+	image := obsPusherImage
 	if c.registryPath != "" {
-		image = fmt.Sprintf("%s/alpine", c.registryPath)
+		image = fmt.Sprintf("%s/obs-pusher", c.registryPath)
 	}
 
+	unprivileged := false
+	readOnly := true
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Labels:    labels,
@@ -262,17 +246,40 @@ func (c *Client) CreateLogPod(namespace, name string, imageArgs []string, labels
 			Namespace: namespace,
 		},
 		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
 			Containers: []corev1.Container{
 				{
-					Name:    name,
-					Image:   image,
-					Command: []string{"/bin/sh", "-c"},
-					Args:    imageArgs,
+					Name:  name,
+					Image: image,
+					Args:  imageArgs,
+					Env:   env,
+					SecurityContext: &corev1.SecurityContext{
+						Capabilities: &corev1.Capabilities{
+							Drop: []corev1.Capability{"ALL"},
+						},
+						Privileged:               &unprivileged,
+						AllowPrivilegeEscalation: &unprivileged,
+						ReadOnlyRootFilesystem:   &readOnly,
+					},
 				},
 			},
 		},
 	}
 
+	if sequenceConfigMap != "" {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: "log-sequence",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: sequenceConfigMap}},
+			},
+		})
+		pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "log-sequence",
+			MountPath: LogSequencePath,
+			ReadOnly:  true,
+		})
+	}
+
 	if c.registryPullSecret != "" {
 		addImagePullSecret(pod, c.registryPullSecret)
 	}
@@ -282,7 +289,7 @@ func (c *Client) CreateLogPod(namespace, name string, imageArgs []string, labels
 	}
 
 	// Add ServiceAccount based on the flag
-	if c.serviceAccountName != "default" {
+	if c.serviceAccountName != "" {
 		addServiceAccount(pod, c.serviceAccountName)
 	}
 
@@ -301,9 +308,10 @@ func (c *Client) CreateService(namespace, name string, labels map[string]string)
 			Selector: labels,
 			Ports: []corev1.ServicePort{
 				{
-					Protocol: corev1.ProtocolTCP,
-					Port:     80,
-					Name:     "http-metrics",
+					Protocol:   corev1.ProtocolTCP,
+					Port:       80,
+					TargetPort: intstr.FromString(metricsPortName),
+					Name:       metricsPortName,
 				},
 			},
 			Type: corev1.ServiceTypeClusterIP,
@@ -314,25 +322,21 @@ func (c *Client) CreateService(namespace, name string, labels map[string]string)
 	return err
 }
 
-// CreateServiceMonitor creates or updates a ServiceMonitor
-func (c *Client) CreateServiceMonitor(namespace, name string, labels map[string]string) error {
+// CreateServiceMonitor creates or updates a ServiceMonitor selecting the services matching the labels
+func (c *Client) CreateServiceMonitor(namespace, name string, labels map[string]string, settings ScrapeSettings) error {
 	serviceMonitor := &monitoringv1.ServiceMonitor{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
 			Namespace: namespace,
-			Labels:    labels,
+			Labels:    settings.monitorLabels(labels),
 		},
 		Spec: monitoringv1.ServiceMonitorSpec{
 
 			Selector: metav1.LabelSelector{
 				MatchLabels: labels,
 			},
-			Endpoints: []monitoringv1.Endpoint{
-				{
-					Port: "http-metrics",
-					Path: "/metrics",
-				},
-			},
+			Endpoints:   []monitoringv1.Endpoint{settings.serviceMonitorEndpoint()},
+			SampleLimit: settings.SampleLimit,
 		},
 	}
 
@@ -357,6 +361,162 @@ func (c *Client) CreateServiceMonitor(namespace, name string, labels map[string]
 	return nil
 }
 
+// CreatePodMonitor creates or updates a PodMonitor scraping the metric pods
+// matching the labels, for Prometheus instances only selecting PodMonitors
+func (c *Client) CreatePodMonitor(namespace, name string, labels map[string]string, settings ScrapeSettings) error {
+	podMonitor := &monitoringv1.PodMonitor{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    settings.monitorLabels(labels),
+		},
+		Spec: monitoringv1.PodMonitorSpec{
+			Selector: metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			PodMetricsEndpoints: []monitoringv1.PodMetricsEndpoint{settings.podMonitorEndpoint()},
+			SampleLimit:         settings.SampleLimit,
+		},
+	}
+
+	existingPodMonitor, err := c.monitoringClientset.MonitoringV1().PodMonitors(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err := c.monitoringClientset.MonitoringV1().PodMonitors(namespace).Create(context.TODO(), podMonitor, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("error creating PodMonitor: %w", err)
+		}
+		fmt.Println("PodMonitor created successfully")
+	} else if err == nil {
+		podMonitor.ResourceVersion = existingPodMonitor.ResourceVersion
+		_, err := c.monitoringClientset.MonitoringV1().PodMonitors(namespace).Update(context.TODO(), podMonitor, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("error updating PodMonitor: %w", err)
+		}
+		fmt.Println("PodMonitor updated successfully")
+	} else {
+		return fmt.Errorf("error getting PodMonitor: %w", err)
+	}
+
+	return nil
+}
+
+// CreatePrometheusRule creates or updates a PrometheusRule holding the rules in a single group
+func (c *Client) CreatePrometheusRule(namespace, name string, labels map[string]string, rules []monitoringv1.Rule) error {
+	prometheusRule := &monitoringv1.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: monitoringv1.PrometheusRuleSpec{
+			Groups: []monitoringv1.RuleGroup{{Name: name, Rules: rules}},
+		},
+	}
+
+	existingPrometheusRule, err := c.monitoringClientset.MonitoringV1().PrometheusRules(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err := c.monitoringClientset.MonitoringV1().PrometheusRules(namespace).Create(context.TODO(), prometheusRule, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("error creating PrometheusRule: %w", err)
+		}
+		fmt.Println("PrometheusRule created successfully")
+	} else if err == nil {
+		prometheusRule.ResourceVersion = existingPrometheusRule.ResourceVersion
+		_, err := c.monitoringClientset.MonitoringV1().PrometheusRules(namespace).Update(context.TODO(), prometheusRule, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("error updating PrometheusRule: %w", err)
+		}
+		fmt.Println("PrometheusRule updated successfully")
+	} else {
+		return fmt.Errorf("error getting PrometheusRule: %w", err)
+	}
+
+	return nil
+}
+
+// CreateConfigMap creates or updates a ConfigMap
+func (c *Client) CreateConfigMap(namespace, name string, labels, data map[string]string) error {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Data: data,
+	}
+
+	existingConfigMap, err := c.clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err := c.clientset.CoreV1().ConfigMaps(namespace).Create(context.TODO(), configMap, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("error creating ConfigMap: %w", err)
+		}
+		fmt.Println("ConfigMap created successfully")
+	} else if err == nil {
+		configMap.ResourceVersion = existingConfigMap.ResourceVersion
+		_, err := c.clientset.CoreV1().ConfigMaps(namespace).Update(context.TODO(), configMap, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("error updating ConfigMap: %w", err)
+		}
+		fmt.Println("ConfigMap updated successfully")
+	} else {
+		return fmt.Errorf("error getting ConfigMap: %w", err)
+	}
+
+	return nil
+}
+
+// CreateSecret creates or updates an Opaque Secret
+func (c *Client) CreateSecret(namespace, name string, labels, data map[string]string) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: data,
+	}
+
+	existingSecret, err := c.clientset.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err := c.clientset.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("error creating Secret: %w", err)
+		}
+		fmt.Println("Secret created successfully")
+	} else if err == nil {
+		secret.ResourceVersion = existingSecret.ResourceVersion
+		_, err := c.clientset.CoreV1().Secrets(namespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("error updating Secret: %w", err)
+		}
+		fmt.Println("Secret updated successfully")
+	} else {
+		return fmt.Errorf("error getting Secret: %w", err)
+	}
+
+	return nil
+}
+
+// SecretEnv returns the environment variables set to the keys of the same
+// name of a Secret
+func SecretEnv(secretName string, keys []string) []corev1.EnvVar {
+	env := make([]corev1.EnvVar, 0, len(keys))
+	for _, key := range keys {
+		env = append(env, corev1.EnvVar{
+			Name: key,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					Key:                  key,
+				},
+			},
+		})
+	}
+	return env
+}
+
 func (c *Client) IsNamespaceExisting(namespace string) (bool, error) {
 	_, err := c.clientset.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
@@ -392,6 +552,15 @@ func (c *Client) IsServiceMonitorExisting(name, namespace string) (bool, error)
 	return err == nil, err
 }
 
+// IsPodMonitorExisting checks if a PodMonitor exists in a namespace
+func (c *Client) IsPodMonitorExisting(name, namespace string) (bool, error) {
+	_, err := c.monitoringClientset.MonitoringV1().PodMonitors(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func (c *Client) DeletePod(namespace, name string) error {
 	err := c.clientset.CoreV1().Pods(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
 	return err
@@ -404,6 +573,22 @@ func (c *Client) DeleteServiceMonitor(namespace, name string) error {
 	err := c.monitoringClientset.MonitoringV1().ServiceMonitors(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
 	return err
 }
+func (c *Client) DeletePodMonitor(namespace, name string) error {
+	err := c.monitoringClientset.MonitoringV1().PodMonitors(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	return err
+}
+func (c *Client) DeletePrometheusRule(namespace, name string) error {
+	err := c.monitoringClientset.MonitoringV1().PrometheusRules(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	return err
+}
+func (c *Client) DeleteConfigMap(namespace, name string) error {
+	err := c.clientset.CoreV1().ConfigMaps(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	return err
+}
+func (c *Client) DeleteSecret(namespace, name string) error {
+	err := c.clientset.CoreV1().Secrets(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	return err
+}
 
 // FetchPodByLabels fetches pods based on labels and checks if any exist
 func (c *Client) FetchPodByLabels(namespace string, labels map[string]string) (*corev1.PodList, error) {
@@ -427,11 +612,29 @@ func (c *Client) FetchServiceByLabels(namespace string, labels map[string]string
 
 }
 
+// FetchConfigMapByLabels fetches the ConfigMaps matching the labels
+func (c *Client) FetchConfigMapByLabels(namespace string, labels map[string]string) (*corev1.ConfigMapList, error) {
+	labelSelector := metav1.LabelSelector{MatchLabels: labels}
+	listOptions := metav1.ListOptions{LabelSelector: metav1.FormatLabelSelector(&labelSelector)}
+	return c.clientset.CoreV1().ConfigMaps(namespace).List(context.TODO(), listOptions)
+}
+
+// FetchSecretByLabels fetches the Secrets matching the labels
+func (c *Client) FetchSecretByLabels(namespace string, labels map[string]string) (*corev1.SecretList, error) {
+	labelSelector := metav1.LabelSelector{MatchLabels: labels}
+	listOptions := metav1.ListOptions{LabelSelector: metav1.FormatLabelSelector(&labelSelector)}
+	return c.clientset.CoreV1().Secrets(namespace).List(context.TODO(), listOptions)
+}
+
 func (c *Client) FetchServiceMonitorByLabels(namespace string, labels map[string]string) (*v1.ServiceMonitorList, error) {
 
 	labelSelector := metav1.LabelSelector{MatchLabels: labels}
 	listOptions := metav1.ListOptions{LabelSelector: metav1.FormatLabelSelector(&labelSelector)}
 	servicemonitors, err := c.monitoringClientset.MonitoringV1().ServiceMonitors(namespace).List(context.TODO(), listOptions)
+	// Without the CRD, as on clusters scraping annotated pods, there is none
+	if errors.IsNotFound(err) {
+		return &v1.ServiceMonitorList{}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -439,6 +642,34 @@ func (c *Client) FetchServiceMonitorByLabels(namespace string, labels map[string
 
 }
 
+func (c *Client) FetchPodMonitorByLabels(namespace string, labels map[string]string) (*v1.PodMonitorList, error) {
+	labelSelector := metav1.LabelSelector{MatchLabels: labels}
+	listOptions := metav1.ListOptions{LabelSelector: metav1.FormatLabelSelector(&labelSelector)}
+	podmonitors, err := c.monitoringClientset.MonitoringV1().PodMonitors(namespace).List(context.TODO(), listOptions)
+	// Without the CRD there is none
+	if errors.IsNotFound(err) {
+		return &v1.PodMonitorList{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return podmonitors, nil
+}
+
+func (c *Client) FetchPrometheusRuleByLabels(namespace string, labels map[string]string) (*v1.PrometheusRuleList, error) {
+	labelSelector := metav1.LabelSelector{MatchLabels: labels}
+	listOptions := metav1.ListOptions{LabelSelector: metav1.FormatLabelSelector(&labelSelector)}
+	prometheusrules, err := c.monitoringClientset.MonitoringV1().PrometheusRules(namespace).List(context.TODO(), listOptions)
+	// Without the CRD there is none
+	if errors.IsNotFound(err) {
+		return &v1.PrometheusRuleList{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return prometheusrules, nil
+}
+
 // WaitForPodDeletion waits until the pod is deleted
 func (c *Client) WaitForPodDeletion(namespace, name string) error {
 	for {
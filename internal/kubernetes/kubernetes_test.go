@@ -2,6 +2,8 @@ package kubernetes
 
 import (
 	"testing"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 )
 
 func TestCreateNamespace(t *testing.T) {
@@ -21,3 +23,48 @@ func TestCreateNamespace(t *testing.T) {
 		t.Errorf("expected 'namespace already exists' error, got %v", err)
 	}
 }
+
+func TestScrapeSettings(t *testing.T) {
+	sampleLimit := uint64(1000)
+	settings := ScrapeSettings{
+		Interval:      "15s",
+		ScrapeTimeout: "10s",
+		HonorLabels:   true,
+		SampleLimit:   &sampleLimit,
+		Labels:        map[string]string{"release": "prometheus", "obs-pusher": "ignored"},
+	}
+	if err := settings.Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	endpoint := settings.serviceMonitorEndpoint()
+	if endpoint.Port != metricsPortName || endpoint.Interval != "15s" || endpoint.ScrapeTimeout != "10s" || !endpoint.HonorLabels {
+		t.Errorf("unexpected endpoint %+v", endpoint)
+	}
+	labels := settings.monitorLabels(map[string]string{"obs-pusher": "metrics"})
+	if labels["release"] != "prometheus" || labels["obs-pusher"] != "metrics" {
+		t.Errorf("expected the extra labels along with the selector ones, got %v", labels)
+	}
+
+	for _, invalid := range []ScrapeSettings{
+		{Interval: "15"},
+		{Interval: "10s", ScrapeTimeout: "30s"},
+		{Scheme: "ftp"},
+		{Scheme: "https"},
+		{TLSConfig: &monitoringv1.SafeTLSConfig{}},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("expected error for %+v, got nil", invalid)
+		}
+	}
+}
+
+func TestSecretEnv(t *testing.T) {
+	env := SecretEnv("checkout", []string{"TENANT_ID", "HEADERS"})
+	if len(env) != 2 || env[0].Name != "TENANT_ID" || env[0].Value != "" {
+		t.Fatalf("expected one variable per key without a value, got %+v", env)
+	}
+	if selector := env[1].ValueFrom.SecretKeyRef; selector.Name != "checkout" || selector.Key != "HEADERS" {
+		t.Errorf("expected the HEADERS key of the checkout Secret, got %+v", selector)
+	}
+}
@@ -0,0 +1,100 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
+)
+
+// ScrapeSettings configures how Prometheus scrapes the metric pods, set on the
+// endpoint of the ServiceMonitor or PodMonitor
+type ScrapeSettings struct {
+	// Interval between two scrapes, e.g. 15s, the Prometheus global one when empty
+	Interval monitoringv1.Duration `json:"interval,omitempty"`
+	// ScrapeTimeout after which a scrape fails, no longer than the interval
+	ScrapeTimeout monitoringv1.Duration `json:"scrapeTimeout,omitempty"`
+	// HonorLabels keeps the labels of the series colliding with target labels
+	HonorLabels       bool                         `json:"honorLabels,omitempty"`
+	MetricRelabelings []monitoringv1.RelabelConfig `json:"metricRelabelings,omitempty"`
+	Relabelings       []monitoringv1.RelabelConfig `json:"relabelings,omitempty"`
+	// SampleLimit fails the scrapes returning more samples, no limit when unset
+	SampleLimit *uint64 `json:"sampleLimit,omitempty"`
+	// Scheme is http or https, http when empty. The exporter pods only serve
+	// plain HTTP, so https and TLSConfig are refused until they serve TLS
+	Scheme    string                      `json:"scheme,omitempty"`
+	TLSConfig *monitoringv1.SafeTLSConfig `json:"tlsConfig,omitempty"`
+	// Labels added to the monitor, e.g. to match the Prometheus serviceMonitorSelector
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Validate checks the durations and the scheme
+func (s ScrapeSettings) Validate() error {
+	var interval, timeout model.Duration
+	var err error
+	if s.Interval != "" {
+		if interval, err = model.ParseDuration(string(s.Interval)); err != nil {
+			return fmt.Errorf("invalid scrape interval %q: %w", s.Interval, err)
+		}
+	}
+	if s.ScrapeTimeout != "" {
+		if timeout, err = model.ParseDuration(string(s.ScrapeTimeout)); err != nil {
+			return fmt.Errorf("invalid scrape timeout %q: %w", s.ScrapeTimeout, err)
+		}
+	}
+	if interval > 0 && timeout > interval {
+		return fmt.Errorf("scrape timeout %s is longer than the scrape interval %s", s.ScrapeTimeout, s.Interval)
+	}
+	if s.Scheme == "https" || s.TLSConfig != nil {
+		return fmt.Errorf("the exporter pods only serve plain HTTP on port %s, https scrapes would always fail", metricsPortName)
+	}
+	if s.Scheme != "" && s.Scheme != "http" {
+		return fmt.Errorf("scrape scheme must be http, got %s", s.Scheme)
+	}
+	return nil
+}
+
+// monitorLabels returns the labels of the monitor, the selector labels along
+// with the extra ones
+func (s ScrapeSettings) monitorLabels(labels map[string]string) map[string]string {
+	monitorLabels := make(map[string]string, len(labels)+len(s.Labels))
+	for key, value := range s.Labels {
+		monitorLabels[key] = value
+	}
+	for key, value := range labels {
+		monitorLabels[key] = value
+	}
+	return monitorLabels
+}
+
+func (s ScrapeSettings) serviceMonitorEndpoint() monitoringv1.Endpoint {
+	endpoint := monitoringv1.Endpoint{
+		Port:                 metricsPortName,
+		Path:                 "/metrics",
+		Scheme:               s.Scheme,
+		Interval:             s.Interval,
+		ScrapeTimeout:        s.ScrapeTimeout,
+		HonorLabels:          s.HonorLabels,
+		MetricRelabelConfigs: s.MetricRelabelings,
+		RelabelConfigs:       s.Relabelings,
+	}
+	if s.TLSConfig != nil {
+		endpoint.TLSConfig = &monitoringv1.TLSConfig{SafeTLSConfig: *s.TLSConfig}
+	}
+	return endpoint
+}
+
+func (s ScrapeSettings) podMonitorEndpoint() monitoringv1.PodMetricsEndpoint {
+	portName := metricsPortName
+	return monitoringv1.PodMetricsEndpoint{
+		Port:                 &portName,
+		Path:                 "/metrics",
+		Scheme:               s.Scheme,
+		Interval:             s.Interval,
+		ScrapeTimeout:        s.ScrapeTimeout,
+		HonorLabels:          s.HonorLabels,
+		MetricRelabelConfigs: s.MetricRelabelings,
+		RelabelConfigs:       s.Relabelings,
+		TLSConfig:            s.TLSConfig,
+	}
+}
@@ -0,0 +1,76 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Graphite sends the samples in the Graphite plaintext protocol over TCP, the
+// dotted path of the metric being the Graphite path and the labels Graphite
+// tags, e.g. application.part.proctime.count;service=checkout 3 1700000000
+type Graphite struct {
+	// Address of the carbon plaintext receiver as host:port
+	Address string
+	// Prefix prepended to every path, e.g. obs-pusher.staging
+	Prefix string
+	// Paths maps metric names to the dotted path they are sent under
+	Paths map[string]string
+}
+
+// Send writes a line per sample, stamped with the given time
+func (g *Graphite) Send(ctx context.Context, families []*dto.MetricFamily, now time.Time) error {
+	var lines strings.Builder
+	for _, sample := range flattenDotted(families, g.Paths) {
+		// Graphite has no representation for NaN and infinities
+		if math.IsNaN(sample.value) || math.IsInf(sample.value, 0) {
+			continue
+		}
+		lines.WriteString(g.path(sample))
+		for _, name := range sortedLabelNames(sample.labels) {
+			if value := sample.labels[name]; value != "" {
+				lines.WriteString(";" + graphiteTag(name) + "=" + graphiteTag(value))
+			}
+		}
+		lines.WriteString(" " + formatFloat(sample.value) + " " + strconv.FormatInt(now.Unix(), 10) + "\n")
+	}
+	if lines.Len() == 0 {
+		return nil
+	}
+
+	connection, err := (&net.Dialer{}).DialContext(ctx, "tcp", g.Address)
+	if err != nil {
+		return fmt.Errorf("error connecting to graphite %s: %w", g.Address, err)
+	}
+	defer connection.Close()
+	if _, err := connection.Write([]byte(lines.String())); err != nil {
+		return fmt.Errorf("error sending to graphite %s: %w", g.Address, err)
+	}
+	return nil
+}
+
+func (g *Graphite) path(sample dottedSample) string {
+	var nodes []string
+	if g.Prefix != "" {
+		nodes = append(nodes, strings.Trim(g.Prefix, "."))
+	}
+	for _, node := range sample.path {
+		nodes = append(nodes, graphiteNode(node))
+	}
+	if sample.suffix != "" {
+		nodes = append(nodes, sample.suffix)
+	}
+	return strings.Join(nodes, ".")
+}
+
+// graphiteNode replaces the characters separating nodes, tags and fields
+var graphiteNode = strings.NewReplacer(".", "_", " ", "_", ";", "_").Replace
+
+// graphiteTag replaces the characters not allowed in tag names and values
+var graphiteTag = strings.NewReplacer(" ", "_", ";", "_", "~", "_", "!", "_", "^", "_").Replace
@@ -0,0 +1,129 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Influx writes the samples in the InfluxDB line protocol through the v2 write
+// API, also served by InfluxDB 1.8 and later. The dotted path of the metric
+// gives the measurement and the field, application.part.proctime being the
+// proctime field of the application.part measurement, and the labels the tags
+type Influx struct {
+	URL    string
+	Org    string
+	Bucket string
+	// Token sent in the Authorization header, user:password on InfluxDB 1.x
+	Token string
+	// Paths maps metric names to the dotted path they are sent under
+	Paths map[string]string
+	// Headers added to every request
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// Send writes the samples, the fields of a measurement sharing the same tags
+// being written on the same line
+func (i *Influx) Send(ctx context.Context, families []*dto.MetricFamily, now time.Time) error {
+	body := encodeLineProtocol(flattenDotted(families, i.Paths), now)
+	if len(body) == 0 {
+		return nil
+	}
+
+	query := url.Values{"bucket": {i.Bucket}, "precision": {"ns"}}
+	if i.Org != "" {
+		query.Set("org", i.Org)
+	}
+	writeURL := strings.TrimSuffix(i.URL, "/") + "/api/v2/write?" + query.Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, writeURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating influx write request: %w", err)
+	}
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	request.Header.Set("User-Agent", "obs-pusher")
+	if i.Token != "" {
+		request.Header.Set("Authorization", "Token "+i.Token)
+	}
+	for name, value := range i.Headers {
+		request.Header.Set(name, value)
+	}
+
+	client := i.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error sending influx write request: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("influx write to %s failed with status %s: %s", i.URL, response.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// encodeLineProtocol encodes the samples as measurement,tags fields timestamp
+// lines, a metric with a single node path being its own measurement with a
+// value field
+func encodeLineProtocol(samples []dottedSample, now time.Time) []byte {
+	type point struct {
+		series string
+		fields []string
+	}
+	var points []*point
+	bySeries := make(map[string]*point)
+	for _, sample := range samples {
+		// Float fields cannot hold NaN nor infinities
+		if math.IsNaN(sample.value) || math.IsInf(sample.value, 0) {
+			continue
+		}
+		measurement := strings.Join(sample.path[:len(sample.path)-1], ".")
+		field := sample.path[len(sample.path)-1]
+		if measurement == "" {
+			measurement, field = field, "value"
+		}
+		switch {
+		case sample.suffix == "":
+		case field == "value":
+			field = sample.suffix
+		default:
+			field += "_" + sample.suffix
+		}
+
+		series := influxMeasurement(measurement)
+		for _, name := range sortedLabelNames(sample.labels) {
+			if value := sample.labels[name]; value != "" {
+				series += "," + influxKey(name) + "=" + influxKey(value)
+			}
+		}
+		if bySeries[series] == nil {
+			bySeries[series] = &point{series: series}
+			points = append(points, bySeries[series])
+		}
+		bySeries[series].fields = append(bySeries[series].fields, influxKey(field)+"="+formatFloat(sample.value))
+	}
+
+	var body bytes.Buffer
+	for _, point := range points {
+		body.WriteString(point.series + " " + strings.Join(point.fields, ",") + " " + strconv.FormatInt(now.UnixNano(), 10) + "\n")
+	}
+	return body.Bytes()
+}
+
+// influxMeasurement escapes the measurement separators
+var influxMeasurement = strings.NewReplacer(",", `\,`, " ", `\ `).Replace
+
+// influxKey escapes the separators of tag keys, tag values and field keys
+var influxKey = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace
@@ -0,0 +1,352 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLP transport protocols
+const (
+	OTLPProtocolGRPC = "grpc"
+	OTLPProtocolHTTP = "http/protobuf"
+)
+
+// otlpExportMethod is the gRPC method of the OTLP metrics service
+const otlpExportMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+
+// OTLP exports the series as OTLP sums, gauges, histograms, exponential
+// histograms and summaries to an OpenTelemetry collector
+type OTLP struct {
+	// Endpoint such as http://collector:4318 for http/protobuf or collector:4317 for grpc,
+	// grpc endpoints use TLS when given with the https scheme
+	Endpoint string
+	// Protocol is grpc or http/protobuf (default)
+	Protocol string
+	// ResourceAttributes describe the entity producing the metrics, e.g. service.name
+	ResourceAttributes map[string]string
+	// Headers added to every request, e.g. an Authorization header
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// Send converts the families to an ExportMetricsServiceRequest and exports it
+func (o *OTLP) Send(ctx context.Context, families []*dto.MetricFamily, now time.Time) error {
+	request := encodeExportRequest(o.resourceMetrics(families, now))
+	if o.Protocol == OTLPProtocolGRPC {
+		return o.sendGRPC(ctx, request)
+	}
+	return o.sendHTTP(ctx, request)
+}
+
+func (o *OTLP) resourceMetrics(families []*dto.MetricFamily, now time.Time) *metricspb.ResourceMetrics {
+	var metrics []*metricspb.Metric
+	for _, family := range families {
+		metrics = append(metrics, otlpMetric(family, uint64(now.UnixNano())))
+	}
+	return &metricspb.ResourceMetrics{
+		Resource: &resourcepb.Resource{Attributes: otlpAttributes(o.ResourceAttributes)},
+		ScopeMetrics: []*metricspb.ScopeMetrics{{
+			Scope:   &commonpb.InstrumentationScope{Name: "obs-pusher"},
+			Metrics: metrics,
+		}},
+	}
+}
+
+// encodeExportRequest encodes an ExportMetricsServiceRequest, whose only field
+// is the repeated resource metrics
+func encodeExportRequest(resourceMetrics *metricspb.ResourceMetrics) []byte {
+	message, _ := proto.Marshal(resourceMetrics)
+	return appendMessage(nil, 1, message)
+}
+
+func (o *OTLP) sendHTTP(ctx context.Context, body []byte) error {
+	endpoint := strings.TrimSuffix(o.Endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/metrics") {
+		endpoint += "/v1/metrics"
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating otlp request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/x-protobuf")
+	o.setHeaders(request)
+
+	response, err := o.client(http.DefaultClient).Do(request)
+	if err != nil {
+		return fmt.Errorf("error sending otlp request: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("otlp export to %s failed with status %s: %s", endpoint, response.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// sendGRPC calls the Export method over HTTP/2, the message being prefixed by
+// the gRPC compression flag and length
+func (o *OTLP) sendGRPC(ctx context.Context, message []byte) error {
+	target, err := url.Parse(o.Endpoint)
+	if err != nil || target.Host == "" {
+		target = &url.URL{Scheme: "http", Host: o.Endpoint}
+	}
+	client := o.client(&http.Client{Transport: &http2.Transport{
+		AllowHTTP: target.Scheme != "https",
+		DialTLSContext: func(ctx context.Context, network, address string, config *tls.Config) (net.Conn, error) {
+			if target.Scheme == "https" {
+				return (&tls.Dialer{Config: config}).DialContext(ctx, network, address)
+			}
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		},
+	}})
+
+	body := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(message)))
+	body = append(body, message...)
+	endpoint := target.Scheme + "://" + target.Host + otlpExportMethod
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating otlp request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/grpc")
+	request.Header.Set("TE", "trailers")
+	o.setHeaders(request)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error sending otlp request: %w", err)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("otlp export to %s failed with status %s", o.Endpoint, response.Status)
+	}
+	// Errors come in the trailers, or in the headers when there is no response message
+	status, statusMessage := response.Trailer.Get("Grpc-Status"), response.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, statusMessage = response.Header.Get("Grpc-Status"), response.Header.Get("Grpc-Message")
+	}
+	if status != "0" {
+		message, _ := url.PathUnescape(statusMessage)
+		return fmt.Errorf("otlp export to %s failed with grpc status %s: %s", o.Endpoint, status, message)
+	}
+	return nil
+}
+
+func (o *OTLP) setHeaders(request *http.Request) {
+	request.Header.Set("User-Agent", "obs-pusher")
+	for name, value := range o.Headers {
+		request.Header.Set(name, value)
+	}
+}
+
+func (o *OTLP) client(fallback *http.Client) *http.Client {
+	if o.Client != nil {
+		return o.Client
+	}
+	return fallback
+}
+
+// otlpMetric converts a metric family, counters becoming monotonic cumulative sums
+func otlpMetric(family *dto.MetricFamily, now uint64) *metricspb.Metric {
+	metric := &metricspb.Metric{Name: family.GetName(), Description: family.GetHelp(), Unit: family.GetUnit()}
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		sum := &metricspb.Sum{AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, IsMonotonic: true}
+		for _, m := range family.GetMetric() {
+			point := numberDataPoint(m, m.GetCounter().GetValue(), now)
+			point.StartTimeUnixNano = startTime(m.GetCounter().GetCreatedTimestamp().AsTime(), now)
+			if exemplar := m.GetCounter().GetExemplar(); exemplar != nil {
+				point.Exemplars = []*metricspb.Exemplar{otlpExemplar(exemplar)}
+			}
+			sum.DataPoints = append(sum.DataPoints, point)
+		}
+		metric.Data = &metricspb.Metric_Sum{Sum: sum}
+	case dto.MetricType_HISTOGRAM:
+		if isExponential(family) {
+			metric.Data = &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: otlpExponentialHistogram(family, now)}
+		} else {
+			metric.Data = &metricspb.Metric_Histogram{Histogram: otlpHistogram(family, now)}
+		}
+	case dto.MetricType_SUMMARY:
+		summary := &metricspb.Summary{}
+		for _, m := range family.GetMetric() {
+			point := &metricspb.SummaryDataPoint{
+				Attributes:        labelAttributes(m),
+				StartTimeUnixNano: startTime(m.GetSummary().GetCreatedTimestamp().AsTime(), now),
+				TimeUnixNano:      now,
+				Count:             m.GetSummary().GetSampleCount(),
+				Sum:               m.GetSummary().GetSampleSum(),
+			}
+			for _, quantile := range m.GetSummary().GetQuantile() {
+				point.QuantileValues = append(point.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{Quantile: quantile.GetQuantile(), Value: quantile.GetValue()})
+			}
+			summary.DataPoints = append(summary.DataPoints, point)
+		}
+		metric.Data = &metricspb.Metric_Summary{Summary: summary}
+	default:
+		gauge := &metricspb.Gauge{}
+		for _, m := range family.GetMetric() {
+			value := m.GetGauge().GetValue()
+			if family.GetType() == dto.MetricType_UNTYPED {
+				value = m.GetUntyped().GetValue()
+			}
+			gauge.DataPoints = append(gauge.DataPoints, numberDataPoint(m, value, now))
+		}
+		metric.Data = &metricspb.Metric_Gauge{Gauge: gauge}
+	}
+	return metric
+}
+
+func numberDataPoint(m *dto.Metric, value float64, now uint64) *metricspb.NumberDataPoint {
+	return &metricspb.NumberDataPoint{
+		Attributes:   labelAttributes(m),
+		TimeUnixNano: now,
+		Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+	}
+}
+
+func otlpHistogram(family *dto.MetricFamily, now uint64) *metricspb.Histogram {
+	histogram := &metricspb.Histogram{AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE}
+	for _, m := range family.GetMetric() {
+		h := m.GetHistogram()
+		point := &metricspb.HistogramDataPoint{
+			Attributes:        labelAttributes(m),
+			StartTimeUnixNano: startTime(h.GetCreatedTimestamp().AsTime(), now),
+			TimeUnixNano:      now,
+			Count:             h.GetSampleCount(),
+			Sum:               proto.Float64(h.GetSampleSum()),
+		}
+		// OTLP bucket counts are not cumulative and end with the +Inf bucket
+		var previous uint64
+		for _, bucket := range h.GetBucket() {
+			point.ExplicitBounds = append(point.ExplicitBounds, bucket.GetUpperBound())
+			point.BucketCounts = append(point.BucketCounts, bucket.GetCumulativeCount()-previous)
+			previous = bucket.GetCumulativeCount()
+			if exemplar := bucket.GetExemplar(); exemplar != nil {
+				point.Exemplars = append(point.Exemplars, otlpExemplar(exemplar))
+			}
+		}
+		point.BucketCounts = append(point.BucketCounts, h.GetSampleCount()-previous)
+		histogram.DataPoints = append(histogram.DataPoints, point)
+	}
+	return histogram
+}
+
+// isExponential reports whether the histograms of the family have exponential buckets
+func isExponential(family *dto.MetricFamily) bool {
+	for _, m := range family.GetMetric() {
+		if m.GetHistogram().Schema == nil {
+			return false
+		}
+	}
+	return len(family.GetMetric()) > 0
+}
+
+func otlpExponentialHistogram(family *dto.MetricFamily, now uint64) *metricspb.ExponentialHistogram {
+	histogram := &metricspb.ExponentialHistogram{AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE}
+	for _, m := range family.GetMetric() {
+		h := m.GetHistogram()
+		histogram.DataPoints = append(histogram.DataPoints, &metricspb.ExponentialHistogramDataPoint{
+			Attributes:        labelAttributes(m),
+			StartTimeUnixNano: startTime(h.GetCreatedTimestamp().AsTime(), now),
+			TimeUnixNano:      now,
+			Count:             h.GetSampleCount(),
+			Sum:               proto.Float64(h.GetSampleSum()),
+			Scale:             h.GetSchema(),
+			ZeroCount:         h.GetZeroCount(),
+			ZeroThreshold:     h.GetZeroThreshold(),
+			Positive:          exponentialBuckets(h.GetPositiveSpan(), h.GetPositiveDelta()),
+			Negative:          exponentialBuckets(h.GetNegativeSpan(), h.GetNegativeDelta()),
+		})
+	}
+	return histogram
+}
+
+// exponentialBuckets converts Prometheus spans and deltas to OTLP dense bucket
+// counts. Prometheus bucket i holds (base^(i-1), base^i] when OTLP bucket i
+// holds (base^i, base^(i+1)].
+func exponentialBuckets(spans []*dto.BucketSpan, deltas []int64) *metricspb.ExponentialHistogramDataPoint_Buckets {
+	buckets := &metricspb.ExponentialHistogramDataPoint_Buckets{}
+	var index int32
+	var count int64
+	var delta int
+	for i, span := range spans {
+		index += span.GetOffset()
+		if i == 0 {
+			buckets.Offset = index - 1
+		} else {
+			for range span.GetOffset() {
+				buckets.BucketCounts = append(buckets.BucketCounts, 0)
+			}
+		}
+		for range span.GetLength() {
+			count += deltas[delta]
+			delta++
+			buckets.BucketCounts = append(buckets.BucketCounts, uint64(count))
+		}
+		index += int32(span.GetLength())
+	}
+	return buckets
+}
+
+// otlpExemplar converts an exemplar, its trace_id label becoming the trace ID
+func otlpExemplar(exemplar *dto.Exemplar) *metricspb.Exemplar {
+	converted := &metricspb.Exemplar{
+		TimeUnixNano: uint64(exemplar.GetTimestamp().AsTime().UnixNano()),
+		Value:        &metricspb.Exemplar_AsDouble{AsDouble: exemplar.GetValue()},
+	}
+	for _, label := range exemplar.GetLabel() {
+		if traceID, err := hex.DecodeString(label.GetValue()); label.GetName() == "trace_id" && err == nil && len(traceID) == 16 {
+			converted.TraceId = traceID
+			continue
+		}
+		converted.FilteredAttributes = append(converted.FilteredAttributes, stringAttribute(label.GetName(), label.GetValue()))
+	}
+	return converted
+}
+
+// startTime returns the created time in nanoseconds, or now when unknown
+func startTime(created time.Time, now uint64) uint64 {
+	if created.UnixNano() <= 0 {
+		return now
+	}
+	return uint64(created.UnixNano())
+}
+
+func labelAttributes(m *dto.Metric) []*commonpb.KeyValue {
+	labels := make(map[string]string, len(m.GetLabel()))
+	for _, label := range m.GetLabel() {
+		labels[label.GetName()] = label.GetValue()
+	}
+	return otlpAttributes(labels)
+}
+
+func otlpAttributes(attributes map[string]string) []*commonpb.KeyValue {
+	var converted []*commonpb.KeyValue
+	for _, name := range sortedLabelNames(attributes) {
+		converted = append(converted, stringAttribute(name, attributes[name]))
+	}
+	return converted
+}
+
+func stringAttribute(name, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: name, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
@@ -0,0 +1,112 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Pushgateway pushes the exposition to a Prometheus Pushgateway group, as a
+// batch job would
+type Pushgateway struct {
+	URL string
+	Job string
+	// Grouping labels identifying the group along with the job
+	Grouping map[string]string
+	// Method is PUT (default) replacing every metric of the group, or POST only
+	// replacing the metrics with the same name
+	Method string
+	// Headers added to every request, e.g. an Authorization header
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// Send pushes the families in the text exposition format
+func (p *Pushgateway) Send(ctx context.Context, families []*dto.MetricFamily, now time.Time) error {
+	var body bytes.Buffer
+	encoder := expfmt.NewEncoder(&body, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return fmt.Errorf("error encoding metrics: %w", err)
+		}
+	}
+
+	method := p.Method
+	if method == "" {
+		method = http.MethodPut
+	}
+	return p.do(ctx, method, &body, string(expfmt.NewFormat(expfmt.TypeTextPlain)))
+}
+
+// Delete removes the group and every metric pushed to it
+func (p *Pushgateway) Delete(ctx context.Context) error {
+	return p.do(ctx, http.MethodDelete, nil, "")
+}
+
+func (p *Pushgateway) do(ctx context.Context, method string, body io.Reader, contentType string) error {
+	groupURL, err := p.groupURL()
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, method, groupURL, body)
+	if err != nil {
+		return fmt.Errorf("error creating pushgateway request: %w", err)
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	request.Header.Set("User-Agent", "obs-pusher")
+	for name, value := range p.Headers {
+		request.Header.Set(name, value)
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error sending pushgateway request: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("%s %s failed with status %s: %s", method, groupURL, response.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// groupURL returns the URL of the group, /metrics/job/<job>{/<label>/<value>},
+// values which cannot be part of a path being base64 encoded
+func (p *Pushgateway) groupURL() (string, error) {
+	if p.Job == "" {
+		return "", fmt.Errorf("a job name is required to push to the pushgateway")
+	}
+	path := strings.TrimSuffix(p.URL, "/") + "/metrics/" + groupingPathSegment("job", p.Job)
+	for _, name := range sortedLabelNames(p.Grouping) {
+		if name == "job" {
+			return "", fmt.Errorf("job cannot be used as a grouping label")
+		}
+		path += "/" + groupingPathSegment(name, p.Grouping[name])
+	}
+	return path, nil
+}
+
+func groupingPathSegment(name, value string) string {
+	if value == "" {
+		return name + "@base64/="
+	}
+	if strings.Contains(value, "/") {
+		return name + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return name + "/" + url.PathEscape(value)
+}
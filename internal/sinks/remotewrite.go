@@ -0,0 +1,122 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/golang/snappy"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// RemoteWrite sends samples to a Prometheus remote-write endpoint, such as
+// Prometheus, Mimir, Cortex or Thanos receive
+type RemoteWrite struct {
+	URL string
+	// Headers added to every request, e.g. an Authorization header
+	Headers map[string]string
+	// TenantID is sent as the X-Scope-OrgID header when set
+	TenantID string
+	Client   *http.Client
+}
+
+// Send encodes the families as a snappy compressed remote-write request
+func (r *RemoteWrite) Send(ctx context.Context, families []*dto.MetricFamily, now time.Time) error {
+	body := snappy.Encode(nil, encodeWriteRequest(families, now))
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating remote write request: %w", err)
+	}
+	request.Header.Set("Content-Encoding", "snappy")
+	request.Header.Set("Content-Type", "application/x-protobuf")
+	request.Header.Set("User-Agent", "obs-pusher")
+	request.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if r.TenantID != "" {
+		request.Header.Set("X-Scope-OrgID", r.TenantID)
+	}
+	for name, value := range r.Headers {
+		request.Header.Set(name, value)
+	}
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error sending remote write request: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("remote write to %s failed with status %s: %s", r.URL, response.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// Remote-write metric types, as in the MetricMetadata message
+var metadataTypes = map[dto.MetricType]uint64{
+	dto.MetricType_COUNTER:   1,
+	dto.MetricType_GAUGE:     2,
+	dto.MetricType_HISTOGRAM: 3,
+	dto.MetricType_SUMMARY:   5,
+}
+
+// encodeWriteRequest encodes a prometheus.WriteRequest message, one time series
+// per sample, all stamped with the given time, followed by the family metadata
+func encodeWriteRequest(families []*dto.MetricFamily, now time.Time) []byte {
+	var request []byte
+	for _, sample := range flatten(families) {
+		labels := map[string]string{"__name__": sample.name}
+		for name, value := range sample.labels {
+			if value != "" {
+				labels[name] = value
+			}
+		}
+
+		var series []byte
+		for _, name := range sortedLabelNames(labels) {
+			var label []byte
+			label = appendString(label, 1, name)
+			label = appendString(label, 2, labels[name])
+			series = appendMessage(series, 1, label)
+		}
+		var point []byte
+		point = protowire.AppendTag(point, 1, protowire.Fixed64Type)
+		point = protowire.AppendFixed64(point, math.Float64bits(sample.value))
+		point = protowire.AppendTag(point, 2, protowire.VarintType)
+		point = protowire.AppendVarint(point, uint64(now.UnixMilli()))
+		series = appendMessage(series, 2, point)
+
+		request = appendMessage(request, 1, series)
+	}
+
+	for _, family := range families {
+		var metadata []byte
+		metadata = protowire.AppendTag(metadata, 1, protowire.VarintType)
+		metadata = protowire.AppendVarint(metadata, metadataTypes[family.GetType()])
+		metadata = appendString(metadata, 2, family.GetName())
+		metadata = appendString(metadata, 4, family.GetHelp())
+		metadata = appendString(metadata, 5, family.GetUnit())
+		request = appendMessage(request, 3, metadata)
+	}
+	return request
+}
+
+func appendString(b []byte, field protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+func appendMessage(b []byte, field protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
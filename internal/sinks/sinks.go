@@ -0,0 +1,204 @@
+// Package sinks delivers the series generated by the exporter straight to a
+// metrics backend, without a pod being scraped
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/exporter"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Sink sends the metric families gathered at a given time to a backend
+type Sink interface {
+	Send(ctx context.Context, families []*dto.MetricFamily, now time.Time) error
+}
+
+// Run gathers the configured series every interval and sends them to the sink
+// until the context is cancelled, or until the duration elapsed when positive.
+// A send interrupted by the end of the run is not an error, unless nothing was
+// sent before
+func Run(ctx context.Context, config exporter.Config, sink Sink, interval, duration time.Duration) error {
+	registry, err := newRegistry(config)
+	if err != nil {
+		return err
+	}
+
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	sent := false
+	for {
+		families, err := registry.Gather()
+		if err != nil {
+			return fmt.Errorf("error gathering metrics: %w", err)
+		}
+		if err := sink.Send(ctx, families, time.Now()); err != nil {
+			if ctx.Err() != nil && sent {
+				return nil
+			}
+			return err
+		}
+		sent = true
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendOnce sends the configured series a single time once the delay elapsed,
+// as a batch job pushing what it accumulated when it ends
+func SendOnce(ctx context.Context, config exporter.Config, sink Sink, delay time.Duration) error {
+	registry, err := newRegistry(config)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-time.After(delay):
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		return fmt.Errorf("error gathering metrics: %w", err)
+	}
+	return sink.Send(ctx, families, time.Now())
+}
+
+func newRegistry(config exporter.Config) (*prometheus.Registry, error) {
+	collector, err := exporter.NewCollector(config)
+	if err != nil {
+		return nil, err
+	}
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collector); err != nil {
+		return nil, fmt.Errorf("error registering collector: %w", err)
+	}
+	return registry, nil
+}
+
+// sample is a single value of a metric family, as a line of the text exposition
+type sample struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// flatten turns metric families into samples, histograms giving _bucket, _sum
+// and _count samples and summaries quantile, _sum and _count samples
+func flatten(families []*dto.MetricFamily) []sample {
+	var samples []sample
+	for _, family := range families {
+		name := family.GetName()
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			with := func(name, value string) map[string]string {
+				extended := maps.Clone(labels)
+				extended[name] = value
+				return extended
+			}
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				samples = append(samples, sample{name, labels, metric.GetCounter().GetValue()})
+			case dto.MetricType_GAUGE:
+				samples = append(samples, sample{name, labels, metric.GetGauge().GetValue()})
+			case dto.MetricType_UNTYPED:
+				samples = append(samples, sample{name, labels, metric.GetUntyped().GetValue()})
+			case dto.MetricType_HISTOGRAM:
+				histogram := metric.GetHistogram()
+				for _, bucket := range histogram.GetBucket() {
+					samples = append(samples, sample{name + "_bucket", with("le", formatFloat(bucket.GetUpperBound())), float64(bucket.GetCumulativeCount())})
+				}
+				samples = append(samples,
+					sample{name + "_bucket", with("le", "+Inf"), float64(histogram.GetSampleCount())},
+					sample{name + "_sum", labels, histogram.GetSampleSum()},
+					sample{name + "_count", labels, float64(histogram.GetSampleCount())},
+				)
+			case dto.MetricType_SUMMARY:
+				summary := metric.GetSummary()
+				for _, quantile := range summary.GetQuantile() {
+					samples = append(samples, sample{name, with("quantile", formatFloat(quantile.GetQuantile())), quantile.GetValue()})
+				}
+				samples = append(samples,
+					sample{name + "_sum", labels, summary.GetSampleSum()},
+					sample{name + "_count", labels, float64(summary.GetSampleCount())},
+				)
+			}
+		}
+	}
+	return samples
+}
+
+// dottedSample is a sample named by a dotted path, as the metrics of the
+// dictionary, for backends with hierarchical names
+type dottedSample struct {
+	// path of the metric, e.g. application.part.proctime
+	path []string
+	// suffix of the sample within the metric, e.g. bucket, sum or count
+	suffix string
+	labels map[string]string
+	value  float64
+}
+
+// flattenDotted flattens the families naming them by the dotted path mapped to
+// their name, a family named after a mapped name with a unit or _total suffix
+// appended keeps it on its last segment, unmapped families keep their name
+func flattenDotted(families []*dto.MetricFamily, paths map[string]string) []dottedSample {
+	var samples []dottedSample
+	for _, family := range families {
+		path := dottedPath(family.GetName(), paths)
+		for _, sample := range flatten([]*dto.MetricFamily{family}) {
+			suffix := strings.TrimPrefix(strings.TrimPrefix(sample.name, family.GetName()), "_")
+			samples = append(samples, dottedSample{path, suffix, sample.labels, sample.value})
+		}
+	}
+	return samples
+}
+
+func dottedPath(name string, paths map[string]string) []string {
+	mapped := ""
+	for prometheusName := range paths {
+		if (name == prometheusName || strings.HasPrefix(name, prometheusName+"_")) && len(prometheusName) > len(mapped) {
+			mapped = prometheusName
+		}
+	}
+	if mapped == "" {
+		return []string{name}
+	}
+	path := strings.Split(paths[mapped], ".")
+	path[len(path)-1] += strings.TrimPrefix(name, mapped)
+	return path
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// sortedLabelNames returns the label names in lexicographic order
+func sortedLabelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,445 @@
+package sinks
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/exporter"
+	"github.com/golang/snappy"
+	dto "github.com/prometheus/client_model/go"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// decodeSeries returns the label pairs of every time series of a write request
+func decodeSeries(t *testing.T, request []byte) []string {
+	t.Helper()
+	var series []string
+	for len(request) > 0 {
+		field, kind, n := protowire.ConsumeTag(request)
+		request = request[n:]
+		if kind != protowire.BytesType {
+			t.Fatalf("unexpected wire type %v", kind)
+		}
+		message, n := protowire.ConsumeBytes(request)
+		request = request[n:]
+		if field != 1 {
+			continue
+		}
+
+		var labels []string
+		for len(message) > 0 {
+			field, _, n := protowire.ConsumeTag(message)
+			message = message[n:]
+			value, n := protowire.ConsumeBytes(message)
+			message = message[n:]
+			if field != 1 {
+				continue
+			}
+			name, n := protowire.ConsumeString(value[1:])
+			labelValue, _ := protowire.ConsumeString(value[1+n+1:])
+			labels = append(labels, name+"="+labelValue)
+		}
+		series = append(series, strings.Join(labels, ","))
+	}
+	return series
+}
+
+func TestRemoteWrite(t *testing.T) {
+	type request struct {
+		headers http.Header
+		body    []byte
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		compressed, _ := io.ReadAll(r.Body)
+		body, _ := snappy.Decode(nil, compressed)
+		requests <- request{r.Header.Clone(), body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	config := exporter.Config{Series: []exporter.Series{
+		{Name: "queue_size", Labels: map[string]string{"service": "checkout"}, Value: 4},
+		{Name: "request_duration_seconds", Type: exporter.TypeHistogram, Buckets: []float64{0.5}},
+	}}
+	sink := &RemoteWrite{URL: server.URL, TenantID: "team-a", Headers: map[string]string{"Authorization": "Bearer token"}}
+	if err := SendOnce(context.Background(), config, sink, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	received := <-requests
+
+	headers := received.headers
+	if headers.Get("X-Scope-OrgID") != "team-a" || headers.Get("Authorization") != "Bearer token" || headers.Get("Content-Encoding") != "snappy" {
+		t.Errorf("unexpected headers %v", headers)
+	}
+	series := decodeSeries(t, received.body)
+	for _, expected := range []string{
+		"__name__=queue_size,service=checkout",
+		"__name__=request_duration_seconds_bucket,le=0.5",
+		"__name__=request_duration_seconds_bucket,le=+Inf",
+		"__name__=request_duration_seconds_count",
+	} {
+		found := false
+		for _, labels := range series {
+			found = found || labels == expected
+		}
+		if !found {
+			t.Errorf("expected series %s, got %v", expected, series)
+		}
+	}
+}
+
+func TestRemoteWriteReportsRejectedRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	config := exporter.Config{Series: []exporter.Series{{Name: "queue_size", Value: 4}}}
+	err := Run(context.Background(), config, &RemoteWrite{URL: server.URL}, time.Second, time.Second)
+	if err == nil || !strings.Contains(err.Error(), "out of order sample") {
+		t.Errorf("expected the rejection message, got %v", err)
+	}
+
+	// A run ending before anything was sent fails instead of reporting success
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer slow.Close()
+	if err := Run(context.Background(), config, &RemoteWrite{URL: slow.URL}, time.Second, 100*time.Millisecond); err == nil {
+		t.Errorf("expected an error for a run which sent nothing, got nil")
+	}
+}
+
+func TestPushgateway(t *testing.T) {
+	var requests []string
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath())
+		data, _ := io.ReadAll(r.Body)
+		body += string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sink := &Pushgateway{URL: server.URL + "/", Job: "nightly", Grouping: map[string]string{"path": "/var/data", "message": "file"}}
+	config := exporter.Config{Series: []exporter.Series{{Name: "last_success_timestamp", Labels: map[string]string{"message": "file"}, Value: 1700000000}}}
+	if err := SendOnce(context.Background(), config, sink, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := sink.Delete(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	group := "/metrics/job/nightly/message/file/path@base64/L3Zhci9kYXRh"
+	if len(requests) != 2 || requests[0] != "PUT "+group || requests[1] != "DELETE "+group {
+		t.Errorf("unexpected requests %v", requests)
+	}
+	if !strings.Contains(body, `last_success_timestamp{message="file"} 1.7e+09`) {
+		t.Errorf("expected the text exposition, got:\n%s", body)
+	}
+}
+
+// decodeExportRequest returns the resource metrics of an ExportMetricsServiceRequest
+func decodeExportRequest(request []byte) ([]*metricspb.ResourceMetrics, error) {
+	var resourceMetrics []*metricspb.ResourceMetrics
+	for len(request) > 0 {
+		field, kind, n := protowire.ConsumeTag(request)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		request = request[n:]
+		if field != 1 || kind != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(field, kind, request)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			request = request[n:]
+			continue
+		}
+		message, n := protowire.ConsumeBytes(request)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		request = request[n:]
+		decoded := &metricspb.ResourceMetrics{}
+		if err := proto.Unmarshal(message, decoded); err != nil {
+			return nil, err
+		}
+		resourceMetrics = append(resourceMetrics, decoded)
+	}
+	return resourceMetrics, nil
+}
+
+// exportedMetrics returns the metrics of the resource exported by a sink, by name
+func exportedMetrics(t *testing.T, request []byte) map[string]*metricspb.Metric {
+	t.Helper()
+	resourceMetrics, err := decodeExportRequest(request)
+	if err != nil || len(resourceMetrics) != 1 {
+		t.Fatalf("expected one resource, got %v (%v)", resourceMetrics, err)
+	}
+	attributes := resourceMetrics[0].GetResource().GetAttributes()
+	if len(attributes) != 1 || attributes[0].GetKey() != "service.name" || attributes[0].GetValue().GetStringValue() != "checkout" {
+		t.Errorf("unexpected resource attributes %v", attributes)
+	}
+	metrics := make(map[string]*metricspb.Metric)
+	for _, metric := range resourceMetrics[0].GetScopeMetrics()[0].GetMetrics() {
+		metrics[metric.GetName()] = metric
+	}
+	return metrics
+}
+
+func TestOTLPHTTP(t *testing.T) {
+	var path string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	config := exporter.Config{Series: []exporter.Series{
+		{Name: "jobs_total", Type: exporter.TypeCounter, Labels: map[string]string{"message": "file"}, Value: 1, Exemplars: true},
+		{Name: "queue_size", Value: 4},
+		{Name: "request_duration_seconds", Type: exporter.TypeHistogram, Buckets: []float64{0.5}, Value: 0.2},
+		{Name: "payload_bytes", Type: exporter.TypeHistogram, Value: 3, Exponential: true},
+	}}
+	sink := &OTLP{Endpoint: server.URL, ResourceAttributes: map[string]string{"service.name": "checkout"}}
+	if err := SendOnce(context.Background(), config, sink, 1100*time.Millisecond); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if path != "/v1/metrics" {
+		t.Errorf("expected a request to /v1/metrics, got %s", path)
+	}
+	metrics := exportedMetrics(t, body)
+	sum := metrics["jobs_total"].GetSum()
+	if !sum.GetIsMonotonic() || sum.GetDataPoints()[0].GetAsDouble() != 1 || sum.GetDataPoints()[0].GetAttributes()[0].GetKey() != "message" || len(sum.GetDataPoints()[0].GetExemplars()[0].GetTraceId()) != 16 {
+		t.Errorf("unexpected sum %v", sum)
+	}
+	if metrics["queue_size"].GetGauge().GetDataPoints()[0].GetAsDouble() != 4 {
+		t.Errorf("unexpected gauge %v", metrics["queue_size"])
+	}
+	if counts := metrics["request_duration_seconds"].GetHistogram().GetDataPoints()[0].GetBucketCounts(); !slices.Equal(counts, []uint64{1, 0}) {
+		t.Errorf("unexpected histogram bucket counts %v", counts)
+	}
+	exponential := metrics["payload_bytes"].GetExponentialHistogram().GetDataPoints()[0]
+	if exponential.GetScale() != exporter.ExponentialScale || exponential.GetPositive().GetOffset() != 12 || !slices.Equal(exponential.GetPositive().GetBucketCounts(), []uint64{1}) {
+		t.Errorf("unexpected exponential histogram %v", exponential)
+	}
+}
+
+func TestOTLPGRPC(t *testing.T) {
+	var body []byte
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export" || r.Header.Get("Content-Type") != "application/grpc" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		framed, _ := io.ReadAll(r.Body)
+		if len(framed) < 5 || int(binary.BigEndian.Uint32(framed[1:5])) != len(framed)-5 {
+			t.Errorf("invalid grpc framing")
+			return
+		}
+		body = framed[5:]
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte{0, 0, 0, 0, 0})
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	config := exporter.Config{Series: []exporter.Series{{Name: "queue_size", Value: 4}}}
+	sink := &OTLP{Endpoint: server.URL, Protocol: OTLPProtocolGRPC, ResourceAttributes: map[string]string{"service.name": "checkout"}, Client: server.Client()}
+	if err := SendOnce(context.Background(), config, sink, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if metrics := exportedMetrics(t, body); metrics["queue_size"] == nil {
+		t.Errorf("expected queue_size to be exported, got %v", metrics)
+	}
+}
+
+func TestStatsD(t *testing.T) {
+	sink := &StatsD{DogStatsD: true}
+	families := func(counter float64, buckets [2]uint64, count, sum float64) []*dto.MetricFamily {
+		return []*dto.MetricFamily{
+			{Name: proto.String("jobs_total"), Type: dto.MetricType_COUNTER.Enum(), Metric: []*dto.Metric{{
+				Label:   []*dto.LabelPair{{Name: proto.String("message"), Value: proto.String("file")}},
+				Counter: &dto.Counter{Value: proto.Float64(counter)},
+			}}},
+			{Name: proto.String("proctime"), Type: dto.MetricType_HISTOGRAM.Enum(), Metric: []*dto.Metric{{
+				Histogram: &dto.Histogram{SampleCount: proto.Uint64(uint64(count)), SampleSum: proto.Float64(sum), Bucket: []*dto.Bucket{
+					{UpperBound: proto.Float64(0.1), CumulativeCount: proto.Uint64(buckets[0])},
+					{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(buckets[1])},
+				}},
+			}}},
+			{Name: proto.String("latency"), Type: dto.MetricType_SUMMARY.Enum(), Metric: []*dto.Metric{{
+				Summary: &dto.Summary{SampleCount: proto.Uint64(uint64(count)), SampleSum: proto.Float64(sum), Quantile: []*dto.Quantile{
+					{Quantile: proto.Float64(0.5), Value: proto.Float64(0.4)},
+					{Quantile: proto.Float64(0.999), Value: proto.Float64(2)},
+				}},
+			}}},
+		}
+	}
+
+	sink.lines(families(5, [2]uint64{1, 1}, 1, 0.05))
+	// 10000 new observations: 1000 up to 0.1, 8999 up to 1 and one above
+	lines := sink.lines(families(8, [2]uint64{1001, 10000}, 10001, 4000.05))
+	expected := []string{
+		"jobs_total:3|c|#message:file",
+		"proctime:0.1|h|@0.001",
+		"proctime:1|h|@0.000111123",
+		"proctime:1|h",
+		"latency:0.4|h|@0.0001",
+		"latency.p50:0.4|g",
+		"latency.p99_9:2|g",
+	}
+	if !slices.Equal(lines, expected) {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+	// A reset counter sends its whole value, series without new observations only their quantiles
+	expected = []string{"jobs_total:2|c|#message:file", "latency.p50:0.4|g", "latency.p99_9:2|g"}
+	if lines := sink.lines(families(2, [2]uint64{1001, 10000}, 10001, 4000.05)); !slices.Equal(lines, expected) {
+		t.Errorf("unexpected lines after reset %v", lines)
+	}
+
+	// Plain StatsD timers are in milliseconds, other observations are distributions
+	sink = &StatsD{Paths: map[string]string{"application_part_proctime": "application.part.proctime"}}
+	lines = sink.lines([]*dto.MetricFamily{
+		{Name: proto.String("application_part_proctime_seconds"), Type: dto.MetricType_HISTOGRAM.Enum(), Metric: []*dto.Metric{{
+			Histogram: &dto.Histogram{SampleCount: proto.Uint64(2), SampleSum: proto.Float64(0.3), Bucket: []*dto.Bucket{
+				{UpperBound: proto.Float64(0.25), CumulativeCount: proto.Uint64(2)},
+			}},
+		}}},
+		{Name: proto.String("payload_bytes"), Type: dto.MetricType_SUMMARY.Enum(), Metric: []*dto.Metric{{
+			Label:   []*dto.LabelPair{{Name: proto.String("service"), Value: proto.String("checkout")}},
+			Summary: &dto.Summary{SampleCount: proto.Uint64(4), SampleSum: proto.Float64(2048)},
+		}}},
+	})
+	expected = []string{"application.part.proctime_seconds:250|ms|@0.5", "payload_bytes:512|d|@0.25"}
+	if !slices.Equal(lines, expected) {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+}
+
+func TestStatsDOverUDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer listener.Close()
+
+	config := exporter.Config{Series: []exporter.Series{{Name: "queue_size", Value: 4}}}
+	if err := SendOnce(context.Background(), config, &StatsD{Address: listener.LocalAddr().String()}, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	buffer := make([]byte, statsdMaxDatagram)
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := listener.ReadFrom(buffer)
+	if err != nil || string(buffer[:n]) != "queue_size:4|g" {
+		t.Errorf("expected a gauge line, got %q (%v)", buffer[:n], err)
+	}
+}
+
+func TestDottedPath(t *testing.T) {
+	paths := map[string]string{"application_part_proctime": "application.part.proctime"}
+	for name, expected := range map[string][]string{
+		"application_part_proctime":         {"application", "part", "proctime"},
+		"application_part_proctime_seconds": {"application", "part", "proctime_seconds"},
+		"application_part_proctimes":        {"application_part_proctimes"},
+		"queue_size":                        {"queue_size"},
+	} {
+		if path := dottedPath(name, paths); !slices.Equal(path, expected) {
+			t.Errorf("expected %s to map to %v, got %v", name, expected, path)
+		}
+	}
+}
+
+func TestGraphite(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer listener.Close()
+	received := make(chan string)
+	go func() {
+		connection, err := listener.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		defer connection.Close()
+		lines, _ := io.ReadAll(connection)
+		received <- string(lines)
+	}()
+
+	config := exporter.Config{Series: []exporter.Series{
+		{Name: "application_part_proctime", Type: exporter.TypeHistogram, Buckets: []float64{0.5}, Labels: map[string]string{"service": "checkout", "host": ""}},
+	}}
+	sink := &Graphite{Address: listener.Addr().String(), Prefix: "staging", Paths: map[string]string{"application_part_proctime": "application.part.proctime"}}
+	if err := SendOnce(context.Background(), config, sink, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	lines := <-received
+	for _, expected := range []string{
+		"staging.application.part.proctime.bucket;le=0.5;service=checkout 0 ",
+		"staging.application.part.proctime.bucket;le=+Inf;service=checkout 0 ",
+		"staging.application.part.proctime.count;service=checkout 0 ",
+	} {
+		if !strings.Contains(lines, expected) {
+			t.Errorf("expected a %q line, got %s", expected, lines)
+		}
+	}
+}
+
+func TestInflux(t *testing.T) {
+	var body []byte
+	var request *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	config := exporter.Config{Series: []exporter.Series{
+		{Name: "application_part_jobs_total", Type: exporter.TypeCounter, Value: 2, Labels: map[string]string{"message": "a file"}},
+		{Name: "application_part_proctime", Type: exporter.TypeSummary},
+		{Name: "queue_size", Value: 4},
+	}}
+	sink := &Influx{URL: server.URL, Org: "team-a", Bucket: "tests", Token: "secret", Paths: map[string]string{
+		"application_part_jobs_total": "application.part.jobs",
+		"application_part_proctime":   "application.part.proctime",
+	}}
+	if err := SendOnce(context.Background(), config, sink, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if request.URL.Path != "/api/v2/write" || request.URL.Query().Get("bucket") != "tests" || request.URL.Query().Get("org") != "team-a" {
+		t.Errorf("unexpected write URL %s", request.URL)
+	}
+	if request.Header.Get("Authorization") != "Token secret" {
+		t.Errorf("unexpected authorization %q", request.Header.Get("Authorization"))
+	}
+	for _, expected := range []string{
+		`application.part,message=a\ file jobs=0 `,
+		"application.part proctime_sum=0,proctime_count=0 ",
+		"queue_size value=4 ",
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("expected a %q line, got %s", expected, body)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// statsdMaxDatagram is the largest UDP payload sent, fitting a 1500 bytes MTU
+const statsdMaxDatagram = 1432
+
+// StatsD sends the series as StatsD lines, counters as the increment since the
+// previous send, gauges as their value and histograms and summaries as the
+// observations made since the previous send. Observations are sent as one
+// sampled line per histogram bucket, at the bucket upper bound, or as one
+// sampled line at their mean for summaries, followed by a gauge per quantile,
+// so a series sends a bounded number of lines however many observations it has.
+// StatsD timers being in milliseconds, the observations of metrics in seconds
+// are sent as timers and the others as distributions keeping their unit
+type StatsD struct {
+	// Address of the StatsD server as host:port
+	Address string
+	// Network is udp (default) or tcp, TCP lines being newline terminated
+	Network string
+	// DogStatsD appends the labels as DogStatsD tags and sends observations as
+	// DogStatsD histograms instead of timers and distributions
+	DogStatsD bool
+	// Paths maps metric names to the dotted path they are sent under
+	Paths map[string]string
+
+	mutex sync.Mutex
+	// previous counter values, and observation counts, sums and cumulative
+	// bucket counts, by series
+	previous map[string][]float64
+}
+
+// Send writes the lines of every series to the StatsD server
+func (s *StatsD) Send(ctx context.Context, families []*dto.MetricFamily, now time.Time) error {
+	lines := s.lines(families)
+	if len(lines) == 0 {
+		return nil
+	}
+
+	network := s.Network
+	if network == "" {
+		network = "udp"
+	}
+	connection, err := (&net.Dialer{}).DialContext(ctx, network, s.Address)
+	if err != nil {
+		return fmt.Errorf("error connecting to statsd server %s: %w", s.Address, err)
+	}
+	defer connection.Close()
+
+	if network != "udp" {
+		_, err = connection.Write([]byte(strings.Join(lines, "\n") + "\n"))
+		if err != nil {
+			return fmt.Errorf("error sending to statsd server %s: %w", s.Address, err)
+		}
+		return nil
+	}
+	for _, datagram := range datagrams(lines) {
+		if _, err := connection.Write([]byte(datagram)); err != nil {
+			return fmt.Errorf("error sending to statsd server %s: %w", s.Address, err)
+		}
+	}
+	return nil
+}
+
+func (s *StatsD) lines(families []*dto.MetricFamily) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.previous == nil {
+		s.previous = make(map[string][]float64)
+	}
+
+	var lines []string
+	for _, family := range families {
+		name := strings.Join(dottedPath(family.GetName(), s.Paths), ".")
+		observationType, scale := s.observationType(family.GetName())
+		for _, metric := range family.GetMetric() {
+			tags := labelTags(metric)
+			key := family.GetName() + "{" + tags + "}"
+			previous := s.previous[key]
+			if !s.DogStatsD {
+				tags = ""
+			}
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				value := metric.GetCounter().GetValue()
+				increment := value - previousValue(previous, 0)
+				if increment < 0 {
+					// The counter was reset, everything counted since is new
+					increment = value
+				}
+				s.previous[key] = []float64{value}
+				lines = appendLine(lines, name, increment, "c", 1, tags)
+			case dto.MetricType_GAUGE:
+				lines = appendLine(lines, name, metric.GetGauge().GetValue(), "g", 1, tags)
+			case dto.MetricType_UNTYPED:
+				lines = appendLine(lines, name, metric.GetUntyped().GetValue(), "g", 1, tags)
+			case dto.MetricType_HISTOGRAM:
+				histogram := metric.GetHistogram()
+				current := []float64{float64(histogram.GetSampleCount()), histogram.GetSampleSum()}
+				for _, bucket := range histogram.GetBucket() {
+					current = append(current, float64(bucket.GetCumulativeCount()))
+				}
+				if current[0] < previousValue(previous, 0) {
+					previous = nil
+				}
+				s.previous[key] = current
+				observations := current[0] - previousValue(previous, 0)
+				if observations <= 0 {
+					continue
+				}
+				mean := (current[1] - previousValue(previous, 1)) / observations
+
+				// Only the buckets are known, observations are sent at the upper bound of
+				// their bucket, those above the last bound at the mean when it is larger
+				below, lastBound := 0.0, math.Inf(-1)
+				for i, bucket := range histogram.GetBucket() {
+					if math.IsInf(bucket.GetUpperBound(), 1) {
+						break
+					}
+					cumulative := current[i+2] - previousValue(previous, i+2)
+					lines = appendLine(lines, name, bucket.GetUpperBound()*scale, observationType, 1/(cumulative-below), tags)
+					below, lastBound = cumulative, bucket.GetUpperBound()
+				}
+				lines = appendLine(lines, name, math.Max(mean, lastBound)*scale, observationType, 1/(observations-below), tags)
+			case dto.MetricType_SUMMARY:
+				summary := metric.GetSummary()
+				current := []float64{float64(summary.GetSampleCount()), summary.GetSampleSum()}
+				if current[0] < previousValue(previous, 0) {
+					previous = nil
+				}
+				s.previous[key] = current
+				if observations := current[0] - previousValue(previous, 0); observations > 0 {
+					// Only the count and sum are known, observations are sent at their mean
+					lines = appendLine(lines, name, (current[1]-previousValue(previous, 1))/observations*scale, observationType, 1/observations, tags)
+				}
+				for _, quantile := range summary.GetQuantile() {
+					quantileName := name + ".p" + strings.ReplaceAll(formatFloat(quantile.GetQuantile()*100), ".", "_")
+					lines = appendLine(lines, quantileName, quantile.GetValue(), "g", 1, tags)
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// observationType returns the type of the observation lines of a metric and
+// the factor applied to their values
+func (s *StatsD) observationType(name string) (string, float64) {
+	switch {
+	case s.DogStatsD:
+		return "h", 1
+	case strings.HasSuffix(name, "_seconds"):
+		return "ms", 1000
+	default:
+		return "d", 1
+	}
+}
+
+// previousValue returns the value at the index of the previous send, zero when there was none
+func previousValue(previous []float64, index int) float64 {
+	if index >= len(previous) {
+		return 0
+	}
+	return previous[index]
+}
+
+// labelTags returns the labels of the metric as DogStatsD tags
+func labelTags(metric *dto.Metric) string {
+	var tags []string
+	for _, label := range metric.GetLabel() {
+		tags = append(tags, label.GetName()+":"+label.GetValue())
+	}
+	return strings.Join(tags, ",")
+}
+
+// appendLine appends a name:value|type[|@rate][|#tags] line, a line with a
+// sample rate of 1/n standing for n identical values. StatsD has no
+// representation for NaN and infinities so those values are skipped, as are
+// lines standing for no value
+func appendLine(lines []string, name string, value float64, metricType string, rate float64, tags string) []string {
+	if math.IsNaN(value) || math.IsInf(value, 0) || math.IsInf(rate, 0) || rate <= 0 {
+		return lines
+	}
+	line := name + ":" + formatFloat(value) + "|" + metricType
+	if rate < 1 {
+		line += "|@" + strconv.FormatFloat(rate, 'g', 6, 64)
+	}
+	if tags != "" {
+		line += "|#" + tags
+	}
+	return append(lines, line)
+}
+
+// datagrams packs the lines into newline separated UDP payloads
+func datagrams(lines []string) []string {
+	var datagrams []string
+	var current string
+	for _, line := range lines {
+		if current != "" && len(current)+1+len(line) > statsdMaxDatagram {
+			datagrams = append(datagrams, current)
+			current = ""
+		}
+		if current != "" {
+			current += "\n"
+		}
+		current += line
+	}
+	if current != "" {
+		datagrams = append(datagrams, current)
+	}
+	return datagrams
+}
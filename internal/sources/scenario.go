@@ -0,0 +1,157 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// MetricScenario lists the exporter pods to create and the metrics each of them serves
+type MetricScenario struct {
+	// Interval in seconds between two counter increments or histogram and summary observations
+	Interval float64       `json:"interval,omitempty"`
+	Groups   []MetricGroup `json:"groups"`
+	// Alerts are alerting rules on the scenario metrics, created as a PrometheusRule by metrics test-alert
+	Alerts []Alert `json:"alerts,omitempty"`
+}
+
+// Alert is an alerting rule, with the fields of a Prometheus rule
+type Alert struct {
+	Alert string `json:"alert"`
+	Expr  string `json:"expr"`
+	// For and KeepFiringFor are Prometheus durations e.g 1m
+	For           string            `json:"for,omitempty"`
+	KeepFiringFor string            `json:"keepFiringFor,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// MetricGroup is a set of metrics served by one exporter pod
+type MetricGroup struct {
+	Name      string           `json:"name"`
+	Namespace string           `json:"namespace,omitempty"`
+	Labels    []string         `json:"labels,omitempty"`
+	Metrics   []ScenarioMetric `json:"metrics"`
+	// Faults injected on the /metrics endpoint of the group exporter, in the
+	// format of the exporter configuration
+	Faults json.RawMessage `json:"faults,omitempty"`
+	// Scrape settings of the group monitor, the command line ones when unset
+	Scrape *Scrape `json:"scrape,omitempty"`
+}
+
+// Scrape configures how Prometheus scrapes the exporter of a group, as the scrape flags do
+type Scrape struct {
+	// Interval between two scrapes, e.g. 15s, the Prometheus global one when empty
+	Interval string `json:"interval,omitempty"`
+	// ScrapeTimeout after which a scrape fails, no longer than the interval
+	ScrapeTimeout     string       `json:"scrapeTimeout,omitempty"`
+	HonorLabels       bool         `json:"honorLabels,omitempty"`
+	MetricRelabelings []Relabeling `json:"metricRelabelings,omitempty"`
+	Relabelings       []Relabeling `json:"relabelings,omitempty"`
+	// SampleLimit fails the scrapes returning more samples, no limit when unset
+	SampleLimit *uint64 `json:"sampleLimit,omitempty"`
+	// Scheme is http or https, http when empty
+	Scheme string     `json:"scheme,omitempty"`
+	TLS    *ScrapeTLS `json:"tls,omitempty"`
+	// Labels added to the monitor, e.g. to match the Prometheus serviceMonitorSelector
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Relabeling is a Prometheus relabel config
+type Relabeling struct {
+	SourceLabels []string `json:"sourceLabels,omitempty"`
+	Separator    *string  `json:"separator,omitempty"`
+	TargetLabel  string   `json:"targetLabel,omitempty"`
+	Regex        string   `json:"regex,omitempty"`
+	Modulus      uint64   `json:"modulus,omitempty"`
+	Replacement  *string  `json:"replacement,omitempty"`
+	Action       string   `json:"action,omitempty"`
+}
+
+// ScrapeTLS configures the TLS connection of https scrapes
+type ScrapeTLS struct {
+	// ServerName checked against the target certificate
+	ServerName         string `json:"serverName,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	// CASecret is the Secret holding the CA of the target certificate as name:key
+	CASecret string `json:"caSecret,omitempty"`
+}
+
+// ScenarioMetric is a series of the exporter configuration which can be
+// completed from a dictionary metric
+type ScenarioMetric struct {
+	// Dictionary is the name of a dictionary metric providing the name, help, type, unit, generator, resets and absent windows when unset
+	Dictionary string
+	// Series holds the other fields of the metric, in the format of the exporter configuration
+	Series json.RawMessage
+}
+
+// UnmarshalJSON separates the dictionary reference from the series fields
+func (m *ScenarioMetric) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if dictionary, ok := fields["dictionary"]; ok {
+		if err := json.Unmarshal(dictionary, &m.Dictionary); err != nil {
+			return fmt.Errorf("invalid dictionary metric name: %w", err)
+		}
+		delete(fields, "dictionary")
+	}
+	series, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	m.Series = series
+	return nil
+}
+
+// ParseScenario reads a YAML or JSON metric scenario, unknown fields being
+// rejected so misspelled settings are not silently ignored
+func ParseScenario(filePath string) (*MetricScenario, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var scenario MetricScenario
+	err = yaml.UnmarshalStrict(data, &scenario)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling scenario: %w", err)
+	}
+	// Group names are the names of the pods and services of the groups
+	names := make(map[string]bool)
+	for _, group := range scenario.Groups {
+		if group.Name == "" {
+			return nil, fmt.Errorf("every scenario group needs a name")
+		}
+		if errs := validation.IsDNS1123Label(group.Name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid scenario group name %q: %s", group.Name, strings.Join(errs, ", "))
+		}
+		if names[group.Name] {
+			return nil, fmt.Errorf("scenario group %s is defined more than once", group.Name)
+		}
+		names[group.Name] = true
+	}
+	for _, alert := range scenario.Alerts {
+		if alert.Alert == "" || alert.Expr == "" {
+			return nil, fmt.Errorf("every scenario alert needs an alert name and an expr")
+		}
+	}
+	return &scenario, nil
+}
+
+// UsesDictionary reports whether a metric of the scenario references the dictionary
+func (s *MetricScenario) UsesDictionary() bool {
+	for _, group := range s.Groups {
+		for _, metric := range group.Metrics {
+			if metric.Dictionary != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
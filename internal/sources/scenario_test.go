@@ -0,0 +1,113 @@
+package sources
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseScenarioKeepsMetricFields(t *testing.T) {
+	scenarioPath := filepath.Join(t.TempDir(), "scenario.yaml")
+	err := os.WriteFile(scenarioPath, []byte(`
+interval: 5
+groups:
+  - name: checkout
+    labels: ["team:payments"]
+    scrape:
+      interval: 10s
+      sampleLimit: 500
+      labels: {release: prometheus}
+      metricRelabelings:
+        - action: drop
+          sourceLabels: [__name__]
+          regex: go_.*
+    metrics:
+      - name: http_requests_total
+        type: counter
+        labels: {method: GET}
+        value: 3
+      - dictionary: application.part.proctime
+        value: 0.25
+`), 0o600)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	scenario, err := ParseScenario(scenarioPath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if scenario.Interval != 5 || len(scenario.Groups) != 1 || !scenario.UsesDictionary() {
+		t.Fatalf("unexpected scenario %+v", scenario)
+	}
+	scrape := scenario.Groups[0].Scrape
+	if scrape == nil || scrape.Interval != "10s" || *scrape.SampleLimit != 500 || scrape.Labels["release"] != "prometheus" || len(scrape.MetricRelabelings) != 1 {
+		t.Errorf("unexpected scrape settings %+v", scrape)
+	}
+
+	metrics := scenario.Groups[0].Metrics
+	if len(metrics) != 2 || metrics[0].Dictionary != "" || metrics[1].Dictionary != "application.part.proctime" {
+		t.Fatalf("unexpected metrics %+v", metrics)
+	}
+	var series map[string]any
+	if err := json.Unmarshal(metrics[0].Series, &series); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if series["name"] != "http_requests_total" || series["value"] != 3.0 || series["labels"].(map[string]any)["method"] != "GET" {
+		t.Errorf("unexpected first metric fields %s", metrics[0].Series)
+	}
+	if string(metrics[1].Series) != `{"value":0.25}` {
+		t.Errorf("expected the dictionary reference out of the metric fields, got %s", metrics[1].Series)
+	}
+}
+
+func TestParseScenarioAlerts(t *testing.T) {
+	scenarioPath := filepath.Join(t.TempDir(), "scenario.yaml")
+	err := os.WriteFile(scenarioPath, []byte(`
+groups:
+  - name: checkout
+    metrics:
+      - name: queue_size
+        value: 50
+alerts:
+  - alert: QueueTooLong
+    expr: queue_size > 40
+    for: 1m
+    labels: {severity: page}
+`), 0o600)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	scenario, err := ParseScenario(scenarioPath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(scenario.Alerts) != 1 || scenario.Alerts[0].Alert != "QueueTooLong" || scenario.Alerts[0].Expr != "queue_size > 40" || scenario.Alerts[0].For != "1m" {
+		t.Errorf("unexpected alerts %+v", scenario.Alerts)
+	}
+
+	err = os.WriteFile(scenarioPath, []byte("groups: [{name: checkout}]\nalerts: [{alert: QueueTooLong}]\n"), 0o600)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := ParseScenario(scenarioPath); err == nil {
+		t.Errorf("expected error for an alert without expr, got nil")
+	}
+}
+
+func TestParseScenarioRejectsInvalidGroups(t *testing.T) {
+	for name, content := range map[string]string{
+		"duplicate group": "groups: [{name: checkout}, {name: checkout}]\n",
+		"invalid name":    "groups: [{name: Checkout_API}]\n",
+		"unknown field":   "groups: [{name: checkout, metrix: [{name: queue_size, value: 50}]}]\n",
+	} {
+		scenarioPath := filepath.Join(t.TempDir(), "scenario.yaml")
+		if err := os.WriteFile(scenarioPath, []byte(content), 0o600); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := ParseScenario(scenarioPath); err == nil {
+			t.Errorf("expected error for a %s, got nil", name)
+		}
+	}
+}
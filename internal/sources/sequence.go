@@ -12,6 +12,8 @@ type SequenceNotification struct {
 	Interval   int      `json:"interval"`
 	Name       string   `json:"name,omitempty"`
 	Labels     []string `json:"labels,omitempty"`
+	// Stream the event is written to, stdout or stderr, the command line one when unset
+	Stream string `json:"stream,omitempty"`
 }
 
 func ParseSequence(jsonFile string) ([]SequenceNotification, error) {
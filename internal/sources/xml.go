@@ -24,11 +24,16 @@ type Log struct {
 }
 
 type Metric struct {
-	Name               string `xml:"name,attr"`
-	FullyQualifiedName string `xml:"fullyQualifiedName,attr"`
-	Type               string `xml:"type,attr"`
-	Description        string `xml:"description,attr"`
-	Tags               string `xml:"tags,attr"`
+	Name               string  `xml:"name,attr"`
+	FullyQualifiedName string  `xml:"fullyQualifiedName,attr"`
+	Type               string  `xml:"type,attr"`
+	Description        string  `xml:"description,attr"`
+	Unit               string  `xml:"unit,attr"`
+	Tags               string  `xml:"tags,attr"`
+	Generator          string  `xml:"generator,attr"`
+	GeneratorParams    string  `xml:"generatorParams,attr"`
+	ResetEvery         float64 `xml:"resetEvery,attr"`
+	Absent             string  `xml:"absent,attr"`
 }
 
 func ReadDictionary(filePath string) (*Dictionary, error) {
@@ -53,18 +58,52 @@ func ReadDictionary(filePath string) (*Dictionary, error) {
 	return &dictionary, nil
 }
 
-func (m *Metric) GenerateMetricTemplate(values map[string]string, metricValue int) string {
-	tags := strings.Split(m.Tags, ",")
-	var tagStringBuilder strings.Builder
-	for _, tag := range tags {
+// PrometheusType maps the dictionary metric type (e.g. Timer, Counter, Gauge,
+// Distribution) to a Prometheus metric type, unknown types are exposed as untyped
+func (m *Metric) PrometheusType() string {
+	switch strings.ToLower(m.Type) {
+	case "counter", "functioncounter":
+		return "counter"
+	case "gauge", "timegauge":
+		return "gauge"
+	case "timer", "longtasktimer", "histogram":
+		return "histogram"
+	case "distribution", "distributionsummary", "summary":
+		return "summary"
+	default:
+		return "untyped"
+	}
+}
+
+// AbsentWindows returns the "start:duration[:every]" windows during which the
+// metric disappears, given in the dictionary separated by commas
+func (m *Metric) AbsentWindows() []string {
+	if m.Absent == "" {
+		return nil
+	}
+	return strings.Split(m.Absent, ",")
+}
+
+// GenerateMetricLabels returns the label set of the metric, filling the dictionary tags with the given values
+func (m *Metric) GenerateMetricLabels(values map[string]string) map[string]string {
+	labels := make(map[string]string)
+	for _, tag := range strings.Split(m.Tags, ",") {
 		keyValue := strings.Split(tag, "=")
 		if len(keyValue) == 2 {
 			tagKey := keyValue[0]
-			tagStringBuilder.WriteString(fmt.Sprintf("%s=\"%s\",", tagKey, values[tagKey]))
+			labels[tagKey] = values[tagKey]
 		}
 	}
-	tagString := strings.TrimRight(tagStringBuilder.String(), ",")
-	return fmt.Sprintf("echo \"%s{%s} %d\" >> /usr/share/nginx/html/metrics;", m.FullyQualifiedName, tagString, metricValue)
+	return labels
+}
+
+// RenderText returns the text of the notification, its {0}, {1}... placeholders replaced by the values
+func (l *Log) RenderText(values []string) string {
+	text := l.Text
+	for i, value := range values {
+		text = strings.ReplaceAll(text, fmt.Sprintf("{%d}", i), value)
+	}
+	return text
 }
 
 func GenerateJSON(filePath string, notificationID string) (string, error) {
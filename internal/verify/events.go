@@ -0,0 +1,274 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a log line found in a log backend
+type Event struct {
+	Timestamp time.Time
+	// Labels are the stream labels in Loki, the other document fields in Elasticsearch
+	Labels map[string]string
+	Line   string
+}
+
+// EventSource searches a log backend for the lines containing a text
+type EventSource interface {
+	// Search returns the lines containing match received since the given time, oldest first
+	Search(ctx context.Context, match string, since time.Time) ([]Event, error)
+}
+
+// Loki searches the log streams of a Loki server through its query_range API
+type Loki struct {
+	URL string
+	// Selector is the LogQL stream selector searched e.g {namespace="default"}
+	Selector string
+	// Headers added to every request, e.g. an X-Scope-OrgID header
+	Headers map[string]string
+	Client  *http.Client
+	// Limit is the largest number of lines returned, 1000 when 0
+	Limit int
+}
+
+// Search runs a LogQL line filter on the selected streams
+func (l *Loki) Search(ctx context.Context, match string, since time.Time) ([]Event, error) {
+	var response struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string `json:"resultType"`
+			Result     []struct {
+				Stream map[string]string `json:"stream"`
+				// Values are timestamp and line pairs, followed by structured metadata on recent versions
+				Values [][]json.RawMessage `json:"values"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	limit := l.Limit
+	if limit == 0 {
+		limit = 1000
+	}
+	query := l.Selector + " |= " + strconv.Quote(match)
+	parameters := url.Values{
+		"query":     {query},
+		"start":     {strconv.FormatInt(since.UnixNano(), 10)},
+		"end":       {strconv.FormatInt(time.Now().UnixNano(), 10)},
+		"limit":     {strconv.Itoa(limit)},
+		"direction": {"forward"},
+	}
+	target := strings.TrimSuffix(l.URL, "/") + "/loki/api/v1/query_range?" + parameters.Encode()
+	if err := doJSON(ctx, l.Client, http.MethodGet, target, l.Headers, nil, &response); err != nil {
+		return nil, err
+	}
+	if response.Status != "success" {
+		return nil, fmt.Errorf("query %s failed: %s", query, response.Error)
+	}
+	if response.Data.ResultType != "streams" {
+		return nil, fmt.Errorf("query %s returned %s, expected streams", query, response.Data.ResultType)
+	}
+
+	var events []Event
+	for _, stream := range response.Data.Result {
+		for _, value := range stream.Values {
+			var rawTimestamp, line string
+			if len(value) < 2 || json.Unmarshal(value[0], &rawTimestamp) != nil || json.Unmarshal(value[1], &line) != nil {
+				return nil, fmt.Errorf("invalid entry returned by query %s", query)
+			}
+			timestamp, err := strconv.ParseInt(rawTimestamp, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp %q returned by query %s", rawTimestamp, query)
+			}
+			events = append(events, Event{Timestamp: time.Unix(0, timestamp), Labels: stream.Stream, Line: line})
+		}
+	}
+	sortEvents(events)
+	return events, nil
+}
+
+// Elasticsearch searches the documents of Elasticsearch or OpenSearch indices
+type Elasticsearch struct {
+	URL string
+	// Index is the index, alias or pattern searched, every index when empty
+	Index string
+	// MessageField holds the log line, message when empty
+	MessageField string
+	// TimestampField holds the time of the line, @timestamp when empty
+	TimestampField string
+	// Headers added to every request, e.g. an Authorization header
+	Headers map[string]string
+	Client  *http.Client
+	// Limit is the largest number of documents returned, 1000 when 0
+	Limit int
+}
+
+// Search runs a phrase query on the message field
+func (e *Elasticsearch) Search(ctx context.Context, match string, since time.Time) ([]Event, error) {
+	messageField := e.MessageField
+	if messageField == "" {
+		messageField = "message"
+	}
+	timestampField := e.TimestampField
+	if timestampField == "" {
+		timestampField = "@timestamp"
+	}
+	index := e.Index
+	if index == "" {
+		index = "_all"
+	}
+	limit := e.Limit
+	if limit == 0 {
+		limit = 1000
+	}
+	body, err := json.Marshal(map[string]any{
+		"size": limit,
+		"sort": []any{map[string]any{timestampField: map[string]any{"order": "asc"}}},
+		"query": map[string]any{"bool": map[string]any{
+			"must":   []any{map[string]any{"match_phrase": map[string]any{messageField: match}}},
+			"filter": []any{map[string]any{"range": map[string]any{timestampField: map[string]any{"gte": since.UTC().Format(time.RFC3339Nano)}}}},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding query: %w", err)
+	}
+
+	var response struct {
+		Hits struct {
+			Hits []struct {
+				Source map[string]any `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	target := strings.TrimSuffix(e.URL, "/") + "/" + url.PathEscape(index) + "/_search"
+	if err := doJSON(ctx, e.Client, http.MethodPost, target, e.Headers, body, &response); err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for _, hit := range response.Hits.Hits {
+		fields := make(map[string]string)
+		flattenFields("", hit.Source, fields)
+		timestamp, err := parseTimestamp(fields[timestampField])
+		if err != nil {
+			return nil, err
+		}
+		event := Event{Timestamp: timestamp, Line: fields[messageField], Labels: fields}
+		delete(fields, messageField)
+		delete(fields, timestampField)
+		events = append(events, event)
+	}
+	sortEvents(events)
+	return events, nil
+}
+
+// flattenFields adds the scalar fields of a document with their dotted path
+func flattenFields(prefix string, value any, fields map[string]string) {
+	switch value := value.(type) {
+	case map[string]any:
+		for name, field := range value {
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			flattenFields(name, field, fields)
+		}
+	case []any:
+		// Arrays are kept as JSON, e.g. tags
+		encoded, _ := json.Marshal(value)
+		fields[prefix] = string(encoded)
+	case float64:
+		fields[prefix] = formatValue(value)
+	case nil:
+	default:
+		fields[prefix] = fmt.Sprint(value)
+	}
+}
+
+// parseTimestamp parses an RFC 3339 date or epoch milliseconds
+func parseTimestamp(value string) (time.Time, error) {
+	if timestamp, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return timestamp, nil
+	}
+	if millis, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.UnixMilli(int64(millis)), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
+
+func sortEvents(events []Event) {
+	slices.SortStableFunc(events, func(a, b Event) int { return a.Timestamp.Compare(b.Timestamp) })
+}
+
+// EventExpectation is an event expected in the log backend
+type EventExpectation struct {
+	// ID of the dictionary event, empty for a free text message
+	ID string
+	// Text is the rendered message of the event
+	Text string
+	// Match is the text as written in the log line, e.g. escaped in JSON, the text itself when empty
+	Match string
+	// MinCount is the number of lines expected, 1 when 0
+	MinCount int
+}
+
+// EventCheck checks the lines of an expected event are in the log backend
+type EventCheck struct {
+	Source      EventSource
+	Expectation EventExpectation
+	// Since is the start of the searched time range
+	Since time.Time
+
+	// missed is set once a search did not return the event, foundAt when one first did
+	missed  bool
+	foundAt time.Time
+}
+
+// Name returns the event ID and text
+func (c *EventCheck) Name() string {
+	if c.Expectation.ID == "" {
+		return c.Expectation.Text
+	}
+	return c.Expectation.ID + ": " + c.Expectation.Text
+}
+
+// Run searches the event and reports the labels of its first line. When an
+// earlier search missed it, it also reports how late it arrived, the time
+// between the line timestamp and the first search returning it, which is only
+// known to the check interval. An event already there at the first search has
+// no lateness, as it could have been ingested at any time before
+func (c *EventCheck) Run(ctx context.Context) (Details, error) {
+	match := c.Expectation.Match
+	if match == "" {
+		match = c.Expectation.Text
+	}
+	events, err := c.Source.Search(ctx, match, c.Since)
+	if err != nil {
+		return nil, err
+	}
+	details := Details{"count": len(events)}
+	if len(events) == 0 {
+		c.missed = true
+	} else {
+		first := events[0]
+		if c.foundAt.IsZero() {
+			c.foundAt = time.Now()
+		}
+		details["timestamp"] = first.Timestamp.UTC().Format(time.RFC3339Nano)
+		if c.missed {
+			details["lateSeconds"] = c.foundAt.Sub(first.Timestamp).Round(time.Millisecond).Seconds()
+		}
+		details["labels"] = first.Labels
+	}
+
+	minCount := max(c.Expectation.MinCount, 1)
+	if len(events) < minCount {
+		return details, fmt.Errorf("found %d lines, expected at least %d", len(events), minCount)
+	}
+	return details, nil
+}
@@ -0,0 +1,203 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/exporter"
+	"github.com/Patrick-Ivann/observability-pusher/internal/generator"
+)
+
+// Prometheus queries the instant query API of a Prometheus compatible server,
+// such as Prometheus, Thanos, Mimir or VictoriaMetrics
+type Prometheus struct {
+	URL string
+	// Headers added to every request, e.g. an Authorization or X-Scope-OrgID header
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// Sample is a series returned by a query with its current value
+type Sample struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+// Query evaluates an instant query returning a vector
+func (p *Prometheus) Query(ctx context.Context, query string) ([]Sample, error) {
+	var response struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string `json:"resultType"`
+			Result     []struct {
+				Metric map[string]string `json:"metric"`
+				Value  [2]any            `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	target := strings.TrimSuffix(p.URL, "/") + "/api/v1/query?" + url.Values{"query": {query}}.Encode()
+	if err := doJSON(ctx, p.Client, http.MethodGet, target, p.Headers, nil, &response); err != nil {
+		return nil, err
+	}
+	if response.Status != "success" {
+		return nil, fmt.Errorf("query %s failed: %s", query, response.Error)
+	}
+	if response.Data.ResultType != "vector" {
+		return nil, fmt.Errorf("query %s returned a %s, expected a vector", query, response.Data.ResultType)
+	}
+
+	var samples []Sample
+	for _, result := range response.Data.Result {
+		rawValue, _ := result.Value[1].(string)
+		value, err := strconv.ParseFloat(rawValue, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q returned by query %s", rawValue, query)
+		}
+		samples = append(samples, Sample{Labels: result.Metric, Value: value})
+	}
+	return samples, nil
+}
+
+// MetricExpectation is a series expected in Prometheus
+type MetricExpectation struct {
+	Name string
+	// AlternativeName the series may be stored under instead, such as the _total
+	// name OpenMetrics scrapes give to counters without the suffix
+	AlternativeName string
+	// Labels the series has, along with the target labels added by Prometheus
+	Labels map[string]string
+	// Value of the series, any value is accepted when nil
+	Value *float64
+	// MinSeries is the number of series expected with the labels, 1 when 0
+	MinSeries int
+}
+
+// Query returns the selector of the expected series
+func (e MetricExpectation) Query() string {
+	var matchers []string
+	for _, name := range slices.Sorted(maps.Keys(e.Labels)) {
+		matchers = append(matchers, name+"="+strconv.Quote(e.Labels[name]))
+	}
+	if e.AlternativeName != "" {
+		// Metric names hold no regular expression metacharacters
+		matchers = append([]string{`__name__=~"` + e.Name + "|" + e.AlternativeName + `"`}, matchers...)
+		return "{" + strings.Join(matchers, ",") + "}"
+	}
+	return e.Name + "{" + strings.Join(matchers, ",") + "}"
+}
+
+// MetricExpectations returns the series generated by the configuration, whether
+// or not they are absent at the moment. Only constant gauges have a known value,
+// the other series only have to be present, histograms and summaries through
+// their _count series and the series of a high cardinality metric through their
+// count
+func MetricExpectations(config exporter.Config) ([]MetricExpectation, error) {
+	expanded, err := config.ExpandedSeries()
+	if err != nil {
+		return nil, err
+	}
+
+	var expectations []MetricExpectation
+	indexes := make(map[string]int)
+	for _, series := range expanded {
+		expectation := MetricExpectation{Name: series.Name, Labels: make(map[string]string), MinSeries: 1}
+		switch series.Type {
+		case exporter.TypeHistogram, exporter.TypeSummary:
+			expectation.Name += "_count"
+		case exporter.TypeCounter:
+			if !strings.HasSuffix(series.Name, "_total") {
+				expectation.AlternativeName = series.Name + "_total"
+			}
+		}
+		// Prometheus drops the labels with an empty value
+		for name, value := range series.Labels {
+			if value != "" {
+				expectation.Labels[name] = value
+			}
+		}
+		if series.Cardinality != nil {
+			expectation.MinSeries = series.Cardinality.Series
+		}
+		constant := (series.Type == exporter.TypeGauge || series.Type == exporter.TypeUntyped) &&
+			(series.Generator == nil || series.Generator.Kind == "" || series.Generator.Kind == generator.KindConstant) &&
+			series.Cardinality == nil
+		if constant {
+			value := float64(series.Value)
+			expectation.Value = &value
+		}
+
+		// Series only differing by empty labels are counted together
+		key := expectation.Query()
+		if index, found := indexes[key]; found {
+			expectations[index].MinSeries += expectation.MinSeries
+			continue
+		}
+		indexes[key] = len(expectations)
+		expectations = append(expectations, expectation)
+	}
+	return expectations, nil
+}
+
+// MetricCheck checks the series of an expectation are in Prometheus
+type MetricCheck struct {
+	Prometheus  *Prometheus
+	Expectation MetricExpectation
+	// Tolerance is the largest accepted difference with the expected value
+	Tolerance float64
+}
+
+// Name returns the query of the expected series
+func (c *MetricCheck) Name() string {
+	return c.Expectation.Query()
+}
+
+// Run queries the series and compares their count and value to the expectation
+func (c *MetricCheck) Run(ctx context.Context) (Details, error) {
+	samples, err := c.Prometheus.Query(ctx, c.Expectation.Query())
+	if err != nil {
+		return nil, err
+	}
+	details := Details{"series": len(samples)}
+	if len(samples) > 0 {
+		details["labels"] = samples[0].Labels
+		details["value"] = formatValue(samples[0].Value)
+	}
+
+	minSeries := max(c.Expectation.MinSeries, 1)
+	if len(samples) < minSeries {
+		return details, fmt.Errorf("found %d series, expected at least %d", len(samples), minSeries)
+	}
+	if c.Expectation.Value == nil {
+		return details, nil
+	}
+	expected := *c.Expectation.Value
+	details["expected"] = formatValue(expected)
+	for _, sample := range samples {
+		if sameValue(sample.Value, expected, c.Tolerance) {
+			details["labels"] = sample.Labels
+			details["value"] = formatValue(sample.Value)
+			return details, nil
+		}
+	}
+	return details, fmt.Errorf("value is %s, expected %s", formatValue(samples[0].Value), formatValue(expected))
+}
+
+func sameValue(value, expected, tolerance float64) bool {
+	if math.IsNaN(expected) || math.IsInf(expected, 0) {
+		return value == expected || (math.IsNaN(value) && math.IsNaN(expected))
+	}
+	return math.Abs(value-expected) <= tolerance
+}
+
+// formatValue formats a value as Prometheus does, JSON cannot hold NaN and infinities
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
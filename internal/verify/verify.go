@@ -0,0 +1,240 @@
+// Package verify checks that what obs-pusher pushed reached the backends,
+// retrying until every check passes or a deadline passes, and reports the
+// outcome as text, JSON or JUnit XML for CI pipelines
+package verify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Report formats
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatJUnit = "junit"
+)
+
+// Check is a single assertion, retried until it passes
+type Check interface {
+	// Name identifies the check in the reports
+	Name() string
+	// Run performs the check once, an error telling why it did not pass
+	Run(ctx context.Context) (Details, error)
+}
+
+// Details are what a check found, included in the reports
+type Details map[string]any
+
+// Result is the outcome of a check
+type Result struct {
+	Name    string  `json:"name"`
+	Passed  bool    `json:"passed"`
+	Message string  `json:"message,omitempty"`
+	Details Details `json:"details,omitempty"`
+	// Attempts is the number of times the check ran
+	Attempts int `json:"attempts"`
+	// Elapsed is the time until the check passed, or until the deadline
+	Elapsed time.Duration `json:"-"`
+}
+
+// MarshalJSON encodes the result with the elapsed time in seconds
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	return json.Marshal(struct {
+		result
+		ElapsedSeconds float64 `json:"elapsedSeconds"`
+	}{result(r), r.Elapsed.Seconds()})
+}
+
+// Run runs the pending checks every interval until all of them passed or the
+// context is done, returning one result per check in the given order
+func Run(ctx context.Context, checks []Check, interval time.Duration) []Result {
+	start := time.Now()
+	results := make([]Result, len(checks))
+	for i, check := range checks {
+		results[i].Name = check.Name()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pending := 0
+		for i, check := range checks {
+			if results[i].Passed {
+				continue
+			}
+			details, err := check.Run(ctx)
+			// A check interrupted by the deadline keeps its previous outcome
+			if err != nil && ctx.Err() != nil && results[i].Attempts > 0 {
+				continue
+			}
+			results[i].Attempts++
+			results[i].Details = details
+			results[i].Elapsed = time.Since(start)
+			results[i].Passed = err == nil
+			results[i].Message = ""
+			if err != nil {
+				results[i].Message = err.Error()
+				pending++
+			}
+		}
+		if pending == 0 {
+			return results
+		}
+
+		select {
+		case <-ctx.Done():
+			return results
+		case <-ticker.C:
+		}
+	}
+}
+
+// Passed reports whether every check passed
+func Passed(results []Result) bool {
+	for _, result := range results {
+		if !result.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteReport writes the results in the format, the suite naming the JUnit test suite
+func WriteReport(w io.Writer, format, suite string, results []Result) error {
+	switch format {
+	case FormatText, "":
+		return writeText(w, results)
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	case FormatJUnit:
+		return writeJUnit(w, suite, results)
+	default:
+		return fmt.Errorf("unknown report format %q, expected %s, %s or %s", format, FormatText, FormatJSON, FormatJUnit)
+	}
+}
+
+func writeText(w io.Writer, results []Result) error {
+	for _, result := range results {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+		}
+		line := fmt.Sprintf("%s %s after %d attempts (%s)", status, result.Name, result.Attempts, result.Elapsed.Round(time.Millisecond))
+		if result.Message != "" {
+			line += ": " + result.Message
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func writeJUnit(w io.Writer, suiteName string, results []Result) error {
+	suite := junitSuite{Name: suiteName, Tests: len(results)}
+	var total time.Duration
+	for _, result := range results {
+		testCase := junitCase{Name: result.Name, Classname: suiteName, Time: junitSeconds(result.Elapsed)}
+		if len(result.Details) > 0 {
+			details, err := json.Marshal(result.Details)
+			if err != nil {
+				return fmt.Errorf("error encoding details of %s: %w", result.Name, err)
+			}
+			testCase.SystemOut = string(details)
+		}
+		if !result.Passed {
+			suite.Failures++
+			testCase.Failure = &junitFailure{Message: result.Message, Text: fmt.Sprintf("%s after %d attempts", result.Message, result.Attempts)}
+		}
+		total = max(total, result.Elapsed)
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Time = junitSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suite); err != nil {
+		return fmt.Errorf("error encoding junit report: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// doJSON sends the request with the JSON body when not nil and decodes the JSON response in value
+func doJSON(ctx context.Context, client *http.Client, method, target string, headers map[string]string, body []byte, value any) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("User-Agent", "obs-pusher")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer response.Body.Close()
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s failed with status %s: %s", method, target, response.Status, bytes.TrimSpace(responseBody))
+	}
+	if err := json.Unmarshal(responseBody, value); err != nil {
+		return fmt.Errorf("error decoding response of %s: %w", target, err)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Patrick-Ivann/observability-pusher/internal/exporter"
+)
+
+func TestMetricExpectations(t *testing.T) {
+	config := exporter.Config{Series: []exporter.Series{
+		{Name: "queue_size", LabelValues: map[string][]string{"queue": {"a", "b"}}, Value: 4},
+		{Name: "jobs_total", Type: exporter.TypeCounter, Labels: map[string]string{"service": "checkout"}, Value: 1},
+		{Name: "request_duration", Type: exporter.TypeHistogram, Unit: "seconds"},
+		{Name: "sessions", Value: 1, Cardinality: &exporter.Cardinality{Series: 3}},
+		{Name: "jobs", Type: exporter.TypeCounter, Value: 1},
+		{Name: "flaky_gauge", Value: 3, Absent: []exporter.Window{{Start: 0, Duration: 60}}},
+	}}
+	expectations, err := MetricExpectations(config)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	var queries []string
+	for _, expectation := range expectations {
+		query := expectation.Query()
+		if expectation.Value != nil {
+			query += "=" + formatValue(*expectation.Value)
+		}
+		if expectation.MinSeries > 1 {
+			query += " x" + formatValue(float64(expectation.MinSeries))
+		}
+		queries = append(queries, query)
+	}
+	expected := []string{
+		`queue_size{queue="a"}=4`,
+		`queue_size{queue="b"}=4`,
+		`jobs_total{service="checkout"}`,
+		`request_duration_seconds_count{}`,
+		`sessions{} x3`,
+		`{__name__=~"jobs|jobs_total"}`,
+		`flaky_gauge{}=3`,
+	}
+	if strings.Join(queries, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %v, got %v", expected, queries)
+	}
+}
+
+func TestMetricCheck(t *testing.T) {
+	var polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		switch {
+		case r.URL.Path != "/api/v1/query":
+			http.NotFound(w, r)
+		// The series arrives on the second poll
+		case query == `queue_size{queue="a"}` && polls.Add(1) > 1:
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"__name__":"queue_size","queue":"a","job":"dummy"},"value":[1700000000,"4"]}]}}`))
+		case query == `temperature{}`:
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"__name__":"temperature"},"value":[1700000000,"21.5"]}]}}`))
+		default:
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		}
+	}))
+	defer server.Close()
+
+	prometheus := &Prometheus{URL: server.URL}
+	four, twenty := 4.0, 20.0
+	checks := []Check{
+		&MetricCheck{Prometheus: prometheus, Expectation: MetricExpectation{Name: "queue_size", Labels: map[string]string{"queue": "a"}, Value: &four}},
+		&MetricCheck{Prometheus: prometheus, Expectation: MetricExpectation{Name: "temperature", Value: &twenty}, Tolerance: 1},
+		&MetricCheck{Prometheus: prometheus, Expectation: MetricExpectation{Name: "missing"}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	results := Run(ctx, checks, 20*time.Millisecond)
+
+	if !results[0].Passed || results[0].Attempts != 2 || results[0].Details["labels"].(map[string]string)["job"] != "dummy" {
+		t.Errorf("expected the series to be found on the second attempt, got %+v", results[0])
+	}
+	if results[1].Passed || results[1].Message != "value is 21.5, expected 20" {
+		t.Errorf("expected a value mismatch, got %+v", results[1])
+	}
+	if results[2].Passed || results[2].Message != "found 0 series, expected at least 1" || results[2].Attempts < 2 {
+		t.Errorf("expected the missing series to be retried until the deadline, got %+v", results[2])
+	}
+	if Passed(results) {
+		t.Errorf("expected the verification to fail")
+	}
+}
+
+func TestReports(t *testing.T) {
+	results := []Result{
+		{Name: `queue_size{queue="a"}`, Passed: true, Attempts: 1, Elapsed: 1500 * time.Millisecond, Details: Details{"series": 1}},
+		{Name: "missing{}", Message: "found 0 series, expected at least 1", Attempts: 3, Elapsed: 2 * time.Second},
+	}
+
+	var junit bytes.Buffer
+	if err := WriteReport(&junit, FormatJUnit, "verify metrics", results); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, expected := range []string{
+		`<testsuite name="verify metrics" tests="2" failures="1" time="2.000">`,
+		`<testcase name="queue_size{queue=&#34;a&#34;}" classname="verify metrics" time="1.500">`,
+		`<system-out>{&#34;series&#34;:1}</system-out>`,
+		`<failure message="found 0 series, expected at least 1">found 0 series, expected at least 1 after 3 attempts</failure>`,
+	} {
+		if !strings.Contains(junit.String(), expected) {
+			t.Errorf("expected %s in the junit report, got %s", expected, junit.String())
+		}
+	}
+
+	var report bytes.Buffer
+	if err := WriteReport(&report, FormatJSON, "verify metrics", results); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(report.Bytes(), &decoded); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if decoded[0]["elapsedSeconds"] != 1.5 || decoded[1]["passed"] != false {
+		t.Errorf("unexpected json report %s", report.String())
+	}
+
+	if err := WriteReport(&report, "yaml", "verify metrics", results); err == nil {
+		t.Errorf("expected error for an unknown format, got nil")
+	}
+}
+
+func TestLokiEventCheck(t *testing.T) {
+	emitted := time.Now().Add(-3 * time.Second)
+	var ingested atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if !ingested.Load() || r.URL.Path != "/loki/api/v1/query_range" || query.Get("query") != `{namespace="default"} |= "disk \\\"sda\\\" full"` || query.Get("start") == "" {
+			w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[]}}`))
+			return
+		}
+		timestamp := strconv.FormatInt(emitted.UnixNano(), 10)
+		later := strconv.FormatInt(emitted.Add(time.Second).UnixNano(), 10)
+		w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[
+			{"stream":{"namespace":"default","pod":"checkout"},"values":[["` + later + `","{\"Text\":\"disk \\\"sda\\\" full\"}",{}]]},
+			{"stream":{"namespace":"default","pod":"payment"},"values":[["` + timestamp + `","{\"Text\":\"disk \\\"sda\\\" full\"}"]]}]}}`))
+	}))
+	defer server.Close()
+
+	source := &Loki{URL: server.URL, Selector: `{namespace="default"}`}
+	check := &EventCheck{Source: source, Expectation: EventExpectation{ID: "E1", Text: `disk "sda" full`, Match: `disk \"sda\" full`, MinCount: 2}, Since: emitted.Add(-time.Minute)}
+	if _, err := check.Run(context.Background()); err == nil {
+		t.Fatalf("expected an error before the lines are ingested, got nil")
+	}
+	ingested.Store(true)
+	details, err := check.Run(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if check.Name() != `E1: disk "sda" full` || details["count"] != 2 || details["labels"].(map[string]string)["pod"] != "payment" {
+		t.Errorf("expected the oldest line first, got %v", details)
+	}
+	if late := details["lateSeconds"].(float64); late < 3 || late > 10 {
+		t.Errorf("expected the line to be about 3 seconds late, got %v", late)
+	}
+
+	check.Expectation.MinCount = 3
+	if _, err := check.Run(context.Background()); err == nil || err.Error() != "found 2 lines, expected at least 3" {
+		t.Errorf("expected missing repetitions to fail, got %v", err)
+	}
+
+	check = &EventCheck{Source: source, Expectation: EventExpectation{Text: `disk "sda" full`, Match: `disk \"sda\" full`}, Since: emitted.Add(-time.Minute)}
+	details, err = check.Run(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, ok := details["lateSeconds"]; ok {
+		t.Errorf("expected no lateness for lines found by the first search, got %v", details)
+	}
+}
+
+func TestElasticsearchEventCheck(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/logs-*/_search" || r.Header.Get("Authorization") != "ApiKey secret" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"hits":{"hits":[{"_source":{"@timestamp":"2024-05-01T10:00:00.5Z","message":"disk full",
+			"kubernetes":{"namespace":"default","labels":{"app":"checkout"}},"tags":["a","b"],"log":{"offset":12}}}]}}`))
+	}))
+	defer server.Close()
+
+	source := &Elasticsearch{URL: server.URL, Index: "logs-*", Headers: map[string]string{"Authorization": "ApiKey secret"}}
+	check := &EventCheck{Source: source, Expectation: EventExpectation{Text: "disk full"}, Since: time.Now().Add(-time.Hour)}
+	details, err := check.Run(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	labels := details["labels"].(map[string]string)
+	if details["timestamp"] != "2024-05-01T10:00:00.5Z" || labels["kubernetes.labels.app"] != "checkout" || labels["tags"] != `["a","b"]` || labels["log.offset"] != "12" || labels["message"] != "" {
+		t.Errorf("unexpected details %v", details)
+	}
+	must := body["query"].(map[string]any)["bool"].(map[string]any)["must"].([]any)[0]
+	if must.(map[string]any)["match_phrase"].(map[string]any)["message"] != "disk full" {
+		t.Errorf("expected a phrase query on the message, got %v", body)
+	}
+}